@@ -0,0 +1,103 @@
+package flagforge
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+)
+
+// Build generates each of the given outputs, and writes it to its path. Every
+// output is generated before any is written, so that an error in one leaves all
+// the files as they were, and each file is written atomically, by renaming a
+// temporary file over it, so that no reader ever sees one half-written.
+func (g *Generator) Build(outputs []Output) error {
+	if len(outputs) == 0 {
+		return fmt.Errorf("no outputs declared")
+	}
+
+	contents := make([][]byte, len(outputs))
+	for i, out := range outputs {
+		b, err := g.buildOutput(out)
+		if err != nil {
+			return fmt.Errorf("failed to generate %s: %w", out.Path, err)
+		}
+		contents[i] = b
+	}
+	for i, out := range outputs {
+		if err := writeFileAtomic(out.Path, contents[i]); err != nil {
+			return fmt.Errorf("failed to write %s: %w", out.Path, err)
+		}
+	}
+	return nil
+}
+
+// buildOutput returns the contents of the given output.
+func (g *Generator) buildOutput(out Output) ([]byte, error) {
+	if out.Path == "" {
+		return nil, fmt.Errorf("output has no path")
+	}
+	f, err := ParseFormat(out.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	// An output's template applies to that output alone.
+	gen := g
+	if out.Template != "" {
+		b, err := os.ReadFile(out.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		clone := *g
+		clone.templates = maps.Clone(g.templates)
+		if err := clone.SetTemplate(f, string(b)); err != nil {
+			return nil, err
+		}
+		gen = &clone
+	}
+
+	var buf bytes.Buffer
+	if out.Header != "" {
+		b, err := os.ReadFile(out.Header)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+		buf.Write(b)
+	}
+	if err := gen.Execute(f, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as the
+// named file, and renames it over the named file.
+func writeFileAtomic(name string, data []byte) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
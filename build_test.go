@@ -0,0 +1,136 @@
+package flagforge
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_ParseFormat(t *testing.T) {
+	for name, f := range formatNames {
+		got, err := ParseFormat(name)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %s: %v", name, err)
+		}
+		if got != f {
+			t.Errorf("ParseFormat(%q) = %s, want %s", name, got, f)
+		}
+	}
+	if _, err := ParseFormat("pdf"); err == nil {
+		t.Error("expected error parsing unknown format")
+	}
+}
+
+func Test_Generator_Build(t *testing.T) {
+	dir := t.TempDir()
+	mustWrite := func(name, contents string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustWrite("header.md", "# Flags\n\n")
+	mustWrite("list.tmpl", "{{ range .Flags }}* {{ .CLI }}\n{{ end }}")
+	mustWrite("docs/.keep", "")
+	mustWrite("flags.toml", `
+	[[outputs]]
+	format = "go"
+	path = "config_flags.go"
+
+	[[outputs]]
+	format = "html"
+	path = "docs/config.md"
+	header = "header.md"
+
+	[[outputs]]
+	format = "markdown"
+	path = "README-flags.md"
+	template = "list.tmpl"
+
+	[[flags]]
+	name = "Timeout"
+	cli = "timeout"
+	type = "time.Duration"
+	short_help = "Request timeout"
+	`)
+
+	cfg, err := NewParser().ParsePath(filepath.Join(dir, "flags.toml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g, err := NewGenerator(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := g.Build(cfg.Outputs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mustTypeCheck(t, mustReadFile(filepath.Join(dir, "config_flags.go")))
+	html := string(mustReadFile(filepath.Join(dir, "docs/config.md")))
+	if !strings.HasPrefix(html, "# Flags\n\n<table") {
+		t.Errorf("HTML output does not begin with header:\n%s", html)
+	}
+	if !strings.Contains(html, "-timeout") {
+		t.Errorf("HTML output does not document flag:\n%s", html)
+	}
+	if md := string(mustReadFile(filepath.Join(dir, "README-flags.md"))); md != "* timeout\n" {
+		t.Errorf("Markdown output from template is %q", md)
+	}
+
+	// The template applies only to its output.
+	if g.template(Markdown, markdownSectionTemplate) != markdownSectionTemplate {
+		t.Error("output's template replaced the generator's")
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		err := g.Build([]Output{
+			{Format: "html", Path: filepath.Join(dir, "docs/config.md")},
+			{Format: "pdf", Path: filepath.Join(dir, "flags.pdf")},
+		})
+		if err == nil {
+			t.Fatal("expected error building unknown format")
+		}
+		if !strings.HasPrefix(string(mustReadFile(filepath.Join(dir, "docs/config.md"))), "# Flags") {
+			t.Error("output was written despite error")
+		}
+		entries, err := os.ReadDir(filepath.Join(dir, "docs"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".tmp") {
+				t.Errorf("temporary file %s left behind", e.Name())
+			}
+		}
+	})
+
+	t.Run("Manifest", func(t *testing.T) {
+		mustWrite("build/flagforge.yaml", `
+input: ../flags.toml
+outputs:
+  - format: sample-toml
+    path: sample.toml
+`)
+		m, err := NewParser().ParseManifest(filepath.Join(dir, "build/flagforge.yaml"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if exp := filepath.Join(dir, "flags.toml"); m.Input != exp {
+			t.Errorf("manifest input is %s, want %s", m.Input, exp)
+		}
+		if len(m.Outputs) != 1 || m.Outputs[0].Path != filepath.Join(dir, "build/sample.toml") {
+			t.Fatalf("manifest outputs are %v", m.Outputs)
+		}
+		if err := g.Build(m.Outputs); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := string(mustReadFile(m.Outputs[0].Path)); !strings.Contains(got, `timeout = "0s"`) {
+			t.Errorf("sample is not as expected:\n%s", got)
+		}
+	})
+}
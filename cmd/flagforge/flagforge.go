@@ -4,41 +4,69 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"slices"
 
 	gen "github.com/rqlite/flagforge"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "schema":
+			if _, err := os.Stdout.Write(gen.InputSchema()); err != nil {
+				printExit("failed to write schema: %v\n", err)
+			}
+			return
+		case "build":
+			build(os.Args[2:])
+			return
+		case "watch":
+			watch(os.Args[2:])
+			return
+		}
+	}
+
 	var (
 		formatStr string
 		out       string
 		header    string
+		tmplPath  string
+		inputStr  string
 	)
 
-	flag.StringVar(&formatStr, "f", "go", "output format: go|markdown|html")
+	flag.StringVar(&formatStr, "f", "go", "output format: go|markdown|html|html-page|jsonschema|sample-toml|sample-yaml|helm-values|helm-args|systemd-env|systemd-exec")
 	flag.StringVar(&out, "o", "", "output file")
 	flag.StringVar(&header, "p", "", "path to a file to copy to the output before the generated content")
+	flag.StringVar(&tmplPath, "t", "", "path to a template replacing the built-in one for the go, markdown or html format")
+	flag.StringVar(&inputStr, "i", "auto", "input format: auto|toml|yaml|json, auto detecting it from the file's extension")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %[1]s [flags] <input file | ->\n       %[1]s build [-m manifest] [input file]\n       %[1]s watch [flags] [input file]\n       %[1]s schema\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	if flag.NArg() < 1 {
-		printExit("no input TOML file provided\n")
+		printExit("no input file provided\n")
 	}
 	inputPath := flag.Arg(0)
 
-	var f gen.Format
-	switch formatStr {
-	case "go":
-		f = gen.Go
-	case "markdown":
-		f = gen.Markdown
-	case "html":
-		f = gen.HTML
-	default:
-		printExit("unknown format: %s\n", formatStr)
+	f, err := gen.ParseFormat(formatStr)
+	if err != nil {
+		printExit("%v\n", err)
 	}
 
+	inputFormat, err := gen.ParseInputFormat(inputStr)
+	if err != nil {
+		printExit("%v\n", err)
+	}
 	p := gen.NewParser()
-	cfg, err := p.ParsePath(inputPath)
+	p.SetInputFormat(inputFormat)
+	var cfg *gen.ParsedConfig
+	if inputPath == "-" {
+		cfg, err = p.ParseReader(os.Stdin)
+	} else {
+		cfg, err = p.ParsePath(inputPath)
+	}
 	if err != nil {
 		printExit("failed to parse input file: %v\n", err)
 	}
@@ -47,6 +75,15 @@ func main() {
 	if err != nil {
 		printExit("failed to create generator: %v\n", err)
 	}
+	if tmplPath != "" {
+		b, err := os.ReadFile(tmplPath)
+		if err != nil {
+			printExit("failed to read template file: %v\n", err)
+		}
+		if err := g.SetTemplate(f, string(b)); err != nil {
+			printExit("failed to set template: %v\n", err)
+		}
+	}
 
 	w := os.Stdout
 	if out != "" {
@@ -70,6 +107,74 @@ func main() {
 	}
 }
 
+// build generates every output declared in the [[outputs]] of the given input
+// file, or in a build manifest.
+func build(args []string) {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	manifest := fs.String("m", "", "path to a YAML build manifest, flagforge.yaml if no input file is given")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s build [-m manifest] [input file]\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 && *manifest != "" {
+		printExit("give either an input file or a manifest, not both\n")
+	}
+
+	g, outputs, _, err := load(*manifest, fs.Arg(0))
+	if err != nil {
+		printExit("%v\n", err)
+	}
+	if err := g.Build(outputs); err != nil {
+		printExit("failed to build outputs: %v\n", err)
+	}
+}
+
+// load parses the given input file, or the build manifest and the input file
+// it names, returning a generator and the outputs to build with it. If neither
+// is given the manifest is flagforge.yaml. The files read, or which would have
+// been read had an error not occurred, are also returned.
+func load(manifest, inputPath string) (*gen.Generator, []gen.Output, []string, error) {
+	p := gen.NewParser()
+	var (
+		outputs []gen.Output
+		files   []string
+	)
+	if inputPath == "" {
+		if manifest == "" {
+			manifest = "flagforge.yaml"
+		}
+		files = append(files, manifest)
+		m, err := p.ParseManifest(manifest)
+		if err != nil {
+			return nil, nil, files, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		inputPath = m.Input
+		outputs = m.Outputs
+	}
+
+	files = append(files, inputPath)
+	cfg, err := p.ParsePath(inputPath)
+	if err != nil {
+		return nil, nil, files, fmt.Errorf("failed to parse input file: %w", err)
+	}
+	if outputs == nil {
+		outputs = cfg.Outputs
+	}
+	files = append(files, cfg.Includes...)
+	files = append(files, cfg.DocsConfig.Stylesheet, cfg.Templates.Go, cfg.Templates.Markdown, cfg.Templates.HTML)
+	for _, out := range outputs {
+		files = append(files, out.Header, out.Template)
+	}
+	files = slices.DeleteFunc(files, func(f string) bool { return f == "" })
+
+	g, err := gen.NewGenerator(cfg)
+	if err != nil {
+		return nil, nil, files, fmt.Errorf("failed to create generator: %w", err)
+	}
+	return g, outputs, files, nil
+}
+
 func printExit(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, format, args...)
 	os.Exit(1)
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	gen "github.com/rqlite/flagforge"
+)
+
+// watchDelay is how long to wait after a change before regenerating, so that
+// the several events an editor's save may cause trigger a single build.
+const watchDelay = 100 * time.Millisecond
+
+// watch builds the outputs as build does, or the single output given by the
+// flags, and builds them again whenever a file they are generated from
+// changes. Errors are reported, but only stop the watch if nothing can be
+// watched at all.
+func watch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	manifest := fs.String("m", "", "path to a YAML build manifest, flagforge.yaml if no input file is given")
+	formatStr := fs.String("f", "", "output format of a single output, instead of the declared outputs")
+	out := fs.String("o", "", "output file of the single output")
+	header := fs.String("p", "", "path to a file to copy to the single output before the generated content")
+	tmplPath := fs.String("t", "", "path to a template replacing the built-in one for the single output")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s watch [-m manifest | -f format -o file] [input file]\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 && *manifest != "" {
+		printExit("give either an input file or a manifest, not both\n")
+	}
+	if (*formatStr == "") != (*out == "") {
+		printExit("give both -f and -o, or neither\n")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		printExit("failed to create watcher: %v\n", err)
+	}
+	defer watcher.Close()
+
+	watched := newWatchSet(watcher.Add)
+	rebuild := func() {
+		g, outputs, files, err := load(*manifest, fs.Arg(0))
+		loaded := err == nil
+		if loaded {
+			if *out != "" {
+				outputs = []gen.Output{{Format: *formatStr, Path: *out, Header: *header, Template: *tmplPath}}
+			}
+			err = g.Build(outputs)
+		}
+		if *header != "" {
+			files = append(files, *header)
+		}
+		if *tmplPath != "" {
+			files = append(files, *tmplPath)
+		}
+		if err != nil {
+			log.Printf("%v", err)
+		} else {
+			log.Printf("generated %d outputs", len(outputs))
+		}
+		watched.update(files, loaded)
+	}
+
+	rebuild()
+	if len(watched.dirs) == 0 {
+		printExit("nothing to watch\n")
+	}
+	var timer <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if watched.files[event.Name] && !event.Has(fsnotify.Chmod) {
+				timer = time.After(watchDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("watch error: %v", err)
+		case <-timer:
+			timer = nil
+			rebuild()
+		}
+	}
+}
+
+// watchSet is the set of files a watch builds outputs from, and the
+// directories watched for changes to them.
+type watchSet struct {
+	files map[string]bool
+	dirs  map[string]bool
+
+	// add starts watching a directory.
+	add func(dir string) error
+}
+
+// newWatchSet returns an empty watch set, which starts watching directories
+// with the given function.
+func newWatchSet(add func(dir string) error) *watchSet {
+	return &watchSet{
+		files: make(map[string]bool),
+		dirs:  make(map[string]bool),
+		add:   add,
+	}
+}
+
+// update watches the given files. If complete is set they replace the files
+// watched before. Otherwise, as when loading the input failed part way, the
+// files are only those read so far, and are added to those watched before so
+// that a fix to any of them is still seen.
+func (w *watchSet) update(files []string, complete bool) {
+	if complete {
+		clear(w.files)
+	}
+	// Watch the directories holding the files, rather than the files
+	// themselves, since many editors save by replacing a file.
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			log.Printf("failed to watch %s: %v", f, err)
+			continue
+		}
+		w.files[abs] = true
+		dir := filepath.Dir(abs)
+		if w.dirs[dir] {
+			continue
+		}
+		if err := w.add(dir); err != nil {
+			log.Printf("failed to watch %s: %v", dir, err)
+			continue
+		}
+		w.dirs[dir] = true
+	}
+}
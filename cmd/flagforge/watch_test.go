@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func Test_WatchSet(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWriteFile(t, filepath.Join(dir, "tls.toml"), `
+[[flags]]
+name = "Cert"
+cli = "cert"
+type = "string"
+short_help = "Certificate"
+`)
+	mustWriteFile(t, filepath.Join(dir, "docs.css"), "body {}")
+	main := filepath.Join(dir, "main.toml")
+	mainTOML := `
+include = ["tls.toml"]
+
+[docs]
+stylesheet = "docs.css"
+
+[[flags]]
+name = "Addr"
+cli = "addr"
+type = "string"
+short_help = "Address"
+`
+
+	var added []string
+	w := newWatchSet(func(dir string) error {
+		added = append(added, dir)
+		return nil
+	})
+	update := func() {
+		t.Helper()
+		_, _, files, err := load("", main)
+		w.update(files, err == nil)
+	}
+	checkFiles := func(want ...string) {
+		t.Helper()
+		var got []string
+		for f := range w.files {
+			got = append(got, filepath.Base(f))
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("watching %v, want %v", got, want)
+		}
+	}
+
+	mustWriteFile(t, main, mainTOML)
+	update()
+	checkFiles("docs.css", "main.toml", "tls.toml")
+	if !slices.Equal(added, []string{dir}) {
+		t.Errorf("watching directories %v, want %v", added, []string{dir})
+	}
+
+	// A file which fails to parse hides what it includes, which must still
+	// be watched.
+	mustWriteFile(t, main, mainTOML+"[[flags")
+	update()
+	checkFiles("docs.css", "main.toml", "tls.toml")
+
+	// Once it parses again, files no longer used are no longer watched.
+	mustWriteFile(t, main, `
+[[flags]]
+name = "Addr"
+cli = "addr"
+type = "string"
+short_help = "Address"
+`)
+	update()
+	checkFiles("main.toml")
+	if len(added) != 1 {
+		t.Errorf("watching directories %v, want %v", added, []string{dir})
+	}
+}
+
+func mustWriteFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,98 @@
+package flagforge
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// FlagTemplate represents a family of flags, declared once and instantiated
+// once for each set of values of the variables its flags use. A variable is
+// used by writing ${name} in any of a flag's strings.
+type FlagTemplate struct {
+	Name  string `mapstructure:"name"`
+	Flags []Flag `mapstructure:"flags"`
+
+	// Instances holds the values of the variables for each instance of the
+	// family. Variable names are case-insensitive.
+	Instances []map[string]string `mapstructure:"instances"`
+}
+
+// placeholder matches a use of a variable in a flag template.
+var placeholder = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// expandFlagTemplates returns the flags of every instance of the given
+// templates, instance by instance, each instance's flags in the order the
+// template declares them.
+func expandFlagTemplates(templates []FlagTemplate) ([]Flag, error) {
+	var flags []Flag
+	for _, tmpl := range templates {
+		if tmpl.Name == "" {
+			return nil, fmt.Errorf("flag template has no name")
+		}
+		if len(tmpl.Instances) == 0 {
+			return nil, fmt.Errorf("flag template %s has no instances", tmpl.Name)
+		}
+		for i, vars := range tmpl.Instances {
+			for _, flag := range tmpl.Flags {
+				expanded, err := expandFlag(flag, vars)
+				if err != nil {
+					return nil, fmt.Errorf("flag template %s, instance %d: %w", tmpl.Name, i+1, err)
+				}
+				flags = append(flags, expanded)
+			}
+		}
+	}
+	return flags, nil
+}
+
+// expandFlag returns the given flag with the variables used in its strings, its
+// lists of strings, and a string default replaced by their values.
+func expandFlag(flag Flag, vars map[string]string) (Flag, error) {
+	expand := func(s string) (string, error) {
+		var err error
+		s = placeholder.ReplaceAllStringFunc(s, func(m string) string {
+			name := placeholder.FindStringSubmatch(m)[1]
+			v, ok := vars[strings.ToLower(name)]
+			if !ok && err == nil {
+				err = fmt.Errorf("variable %s is not set", name)
+			}
+			return v
+		})
+		return s, err
+	}
+
+	v := reflect.ValueOf(&flag).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.String:
+			s, err := expand(field.String())
+			if err != nil {
+				return Flag{}, err
+			}
+			field.SetString(s)
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+			// Copy the list, which is shared by every instance.
+			list := make([]string, field.Len())
+			for j := range list {
+				s, err := expand(field.Index(j).String())
+				if err != nil {
+					return Flag{}, err
+				}
+				list[j] = s
+			}
+			if !field.IsNil() {
+				field.Set(reflect.ValueOf(list))
+			}
+		case field.Kind() == reflect.Interface && field.Elem().Kind() == reflect.String:
+			s, err := expand(field.Elem().String())
+			if err != nil {
+				return Flag{}, err
+			}
+			field.Set(reflect.ValueOf(s))
+		}
+	}
+	return flag, nil
+}
@@ -0,0 +1,162 @@
+package flagforge
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_Parser_FlagTemplates(t *testing.T) {
+	toml := `
+	[[flag_templates]]
+	name = "tls"
+	instances = [
+		{ prefix = "http", Name = "HTTP", desc = "HTTPS" },
+		{ prefix = "node", name = "Node", desc = "inter-node communications" },
+	]
+
+	[[flag_templates.flags]]
+	name = "${name}X509CACert"
+	cli = "${prefix}-ca-cert"
+	type = "string"
+	default = "/etc/${prefix}/ca.pem"
+	short_help = "Path to the CA certificate file for ${desc}"
+	examples = ["/etc/${prefix}/other-ca.pem"]
+	section = "TLS"
+
+	[[flag_templates.flags]]
+	name = "${Name}VerifyClient"
+	cli = "${prefix}-verify-client"
+	type = "bool"
+	default = false
+	short_help = "Enable mutual TLS for ${desc}"
+	section = "TLS"
+
+	[[flags]]
+	name = "NodeID"
+	cli = "node-id"
+	type = "string"
+	short_help = "Unique ID for node"
+	section = "General"
+	`
+	cfg, err := NewParser().ParseReader(strings.NewReader(toml))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Flag{
+		{
+			Name:      "HTTPX509CACert",
+			CLI:       "http-ca-cert",
+			Type:      "string",
+			Default:   "/etc/http/ca.pem",
+			ShortHelp: "Path to the CA certificate file for HTTPS",
+			Examples:  []string{"/etc/http/other-ca.pem"},
+			Section:   "TLS",
+		},
+		{
+			Name:      "HTTPVerifyClient",
+			CLI:       "http-verify-client",
+			Type:      "bool",
+			Default:   false,
+			ShortHelp: "Enable mutual TLS for HTTPS",
+			Section:   "TLS",
+		},
+		{
+			Name:      "NodeX509CACert",
+			CLI:       "node-ca-cert",
+			Type:      "string",
+			Default:   "/etc/node/ca.pem",
+			ShortHelp: "Path to the CA certificate file for inter-node communications",
+			Examples:  []string{"/etc/node/other-ca.pem"},
+			Section:   "TLS",
+		},
+		{
+			Name:      "NodeVerifyClient",
+			CLI:       "node-verify-client",
+			Type:      "bool",
+			Default:   false,
+			ShortHelp: "Enable mutual TLS for inter-node communications",
+			Section:   "TLS",
+		},
+		{
+			Name:      "NodeID",
+			CLI:       "node-id",
+			Type:      "string",
+			ShortHelp: "Unique ID for node",
+			Section:   "General",
+		},
+	}
+	if !reflect.DeepEqual(cfg.Flags, want) {
+		t.Fatalf("flags are\n%+v\nwant\n%+v", cfg.Flags, want)
+	}
+
+	gen, err := NewGenerator(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf strings.Builder
+	if err := gen.Execute(Go, &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mustTypeCheck(t, []byte(buf.String()))
+}
+
+func Test_Parser_FlagTemplateErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		toml string
+		err  string
+	}{
+		"UnsetVariable": {
+			toml: `
+			[[flag_templates]]
+			name = "tls"
+			instances = [{ prefix = "http" }, { prefx = "node" }]
+
+			[[flag_templates.flags]]
+			name = "${prefix}Cert"
+			cli = "${prefix}-cert"
+			type = "string"
+			short_help = "Certificate"
+			`,
+			err: "flag template tls, instance 2: variable prefix is not set",
+		},
+		"NoInstances": {
+			toml: `
+			[[flag_templates]]
+			name = "tls"
+
+			[[flag_templates.flags]]
+			name = "Cert"
+			cli = "cert"
+			type = "string"
+			short_help = "Certificate"
+			`,
+			err: "flag template tls has no instances",
+		},
+		"Duplicate": {
+			toml: `
+			[[flag_templates]]
+			name = "tls"
+			instances = [{ prefix = "http" }, { prefix = "http" }]
+
+			[[flag_templates.flags]]
+			name = "${prefix}Cert"
+			cli = "${prefix}-cert"
+			type = "string"
+			short_help = "Certificate"
+			`,
+			err: "flag httpCert is defined twice in input",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewParser().ParseReader(strings.NewReader(tt.toml))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error %q does not contain %q", err, tt.err)
+			}
+		})
+	}
+}
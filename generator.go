@@ -3,11 +3,24 @@ package flagforge
 import (
 	"bytes"
 	"fmt"
+	"go/ast"
 	"go/format"
+	"go/parser"
 	"io"
+	"math"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
 	"strings"
 	"text/template"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
 )
 
 const flagTemplate = `
@@ -15,12 +28,9 @@ const flagTemplate = `
 package {{ .Pkg }}
 
 import (
-	"errors"
-	"flag"
-	"fmt"
-	"os"
-    "strings"
-	"time"
+{{- range .Imports }}
+	"{{ . }}"
+{{- end }}
 )
 
 // {{ .ConfigType }} represents all configuration options.
@@ -29,20 +39,66 @@ type {{ .ConfigType }} struct {
 	// {{ .ShortHelp }}
 	{{ .Name }} {{ .Type }}
 {{- end }}
+{{- if .Nested }}
+{{- range .Sections }}
+	// {{ .Field }} holds the options in the "{{ .Name }}" section.
+	{{ .Field }} {{ .Type }}
+{{- end }}
+{{- else }}
 {{- range .Flags }}
-	// {{ .ShortHelp }}
-	{{- if eq .Type "filepath" }}
-	{{ .Name }} string ` + "`filepath:\"true\"`" + `
-	{{- else }}
-	{{ .Name }} {{ .Type }}
-	{{- end }}
+	{{- template "field" . }}
+{{- end }}
 {{- end }}
 }
+{{- if .Nested }}
+{{- range .Sections }}
+
+// {{ .Type }} represents the configuration options in the "{{ .Name }}" section.
+type {{ .Type }} struct {
+{{- range .Flags }}
+	{{- template "field" . }}
+{{- end }}
+}
+{{- end }}
+{{- end }}
+
+// ForgeOption configures ForgeWithOptions.
+type ForgeOption func(*forgeOptions)
+
+type forgeOptions struct {
+	output io.Writer
+}
+
+// WithOutput sets the destination for usage and error messages. If not set,
+// they are written to os.Stderr.
+func WithOutput(w io.Writer) ForgeOption {
+	return func(o *forgeOptions) {
+		o.output = w
+	}
+}
 
 // Forge sets up and parses command-line flags.
 func Forge(arguments []string) (*flag.FlagSet, *{{ .ConfigType }}, error) {
+	return forge(arguments, os.Stderr, flag.{{ .FSErrorHandling }})
+}
+
+// ForgeWithOptions sets up and parses command-line flags. Unlike Forge it
+// never exits the process or panics, regardless of the configured error
+// handling: any parse error, including flag.ErrHelp, is returned.
+func ForgeWithOptions(arguments []string, opts ...ForgeOption) (*flag.FlagSet, *{{ .ConfigType }}, error) {
+	o := &forgeOptions{
+		output: os.Stderr,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return forge(arguments, o.output, flag.ContinueOnError)
+}
+
+func forge(arguments []string, output io.Writer, errorHandling flag.ErrorHandling) (*flag.FlagSet, *{{ .ConfigType }}, error) {
 	config := &{{ .ConfigType }}{}
-	fs := flag.NewFlagSet("{{ .FSName }}", flag.{{ .FSErrorHandling }})
+	fs := flag.NewFlagSet("{{ .FSName }}", errorHandling)
+	fs.SetOutput(output)
 {{- range $index, $element := .Args }}
 	if len(arguments) <= {{ $index }} {
 		return nil, nil, fmtError("missing required argument: {{ $element.Name }}")
@@ -50,25 +106,69 @@ func Forge(arguments []string) (*flag.FlagSet, *{{ .ConfigType }}, error) {
 {{- end }}
 {{- range .Flags }}
 	{{- if or (eq .Type "string") (eq .Type "filepath") }}
-	fs.StringVar(&config.{{ .Name }}, "{{ .CLI }}", "{{ .Default }}", "{{ .ShortHelp }}")
+	fs.StringVar(&config.{{ .Field }}, "{{ .CLI }}", {{ printf "%q" .Default }}, "{{ .ShortHelp }}")
 	{{- else if eq .Type "bool" }}
-	fs.BoolVar(&config.{{ .Name }}, "{{ .CLI }}", {{ .Default }}, "{{ .ShortHelp }}")
+	fs.BoolVar(&config.{{ .Field }}, "{{ .CLI }}", {{ .Default }}, "{{ .ShortHelp }}")
 	{{- else if eq .Type "int" }}
-	fs.IntVar(&config.{{ .Name }}, "{{ .CLI }}", {{ .Default }}, "{{ .ShortHelp }}")
+	fs.IntVar(&config.{{ .Field }}, "{{ .CLI }}", {{ .Default }}, "{{ .ShortHelp }}")
 	{{- else if eq .Type "uint64" }}
-	fs.Uint64Var(&config.{{ .Name }}, "{{ .CLI }}", {{ .Default }}, "{{ .ShortHelp }}")
+	fs.Uint64Var(&config.{{ .Field }}, "{{ .CLI }}", {{ .Default }}, "{{ .ShortHelp }}")
 	{{- else if eq .Type "int64" }}
-	fs.Int64Var(&config.{{ .Name }}, "{{ .CLI }}", {{ .Default }}, "{{ .ShortHelp }}")
+	fs.Int64Var(&config.{{ .Field }}, "{{ .CLI }}", {{ .Default }}, "{{ .ShortHelp }}")
+	{{- else if eq .Type "uint" }}
+	fs.UintVar(&config.{{ .Field }}, "{{ .CLI }}", {{ .Default }}, "{{ .ShortHelp }}")
+	{{- else if or (eq .Type "int32") (eq .Type "uint32") (eq .Type "uint16") }}
+	config.{{ .Field }} = {{ .Default }}
+	fs.Var(intValue[{{ .Type }}]{&config.{{ .Field }}}, "{{ .CLI }}", "{{ .ShortHelp }}")
+	{{- else if eq .Type "counter" }}
+	config.{{ .Field }} = {{ .Default }}
+	fs.Var(counterValue{&config.{{ .Field }}, {{ .Max }}}, "{{ .CLI }}", "{{ .ShortHelp }}")
+	{{- else if eq .Type "float64" }}
+	fs.Float64Var(&config.{{ .Field }}, "{{ .CLI }}", {{ .Default }}, "{{ .ShortHelp }}")
 	{{- else if eq .Type "time.Duration" }}
-	fs.DurationVar(&config.{{ .Name }}, "{{ .CLI }}", mustParseDuration("{{ .Default }}"), "{{ .ShortHelp }}")
+	fs.DurationVar(&config.{{ .Field }}, "{{ .CLI }}", mustParseDuration({{ printf "%q" .Default }}), "{{ .ShortHelp }}")
 	{{- else if eq .Type "[]string" }}
-	var tmp{{ .Name }} string
-	fs.StringVar(&tmp{{ .Name }}, "{{ .CLI }}", "{{ .Default }}", "{{ .ShortHelp }}")
+	var {{ .Tmp }} string
+	fs.StringVar(&{{ .Tmp }}, "{{ .CLI }}", {{ printf "%q" .Default }}, "{{ .ShortHelp }}")
+	{{- else if eq .Type "hostport" }}
+	config.{{ .Field }} = {{ printf "%q" .Default }}
+	fs.Var((*hostPortValue)(&config.{{ .Field }}), "{{ .CLI }}", "{{ .ShortHelp }}")
+	{{- else if eq .Type "ip" }}
+	fs.TextVar(&config.{{ .Field }}, "{{ .CLI }}", mustUnmarshalText[netip.Addr]({{ printf "%q" .Default }}), "{{ .ShortHelp }}")
+	{{- else if eq .Type "cidr" }}
+	fs.TextVar(&config.{{ .Field }}, "{{ .CLI }}", mustUnmarshalText[netip.Prefix]({{ printf "%q" .Default }}), "{{ .ShortHelp }}")
+	{{- else if eq .Type "url" }}
+	config.{{ .Field }} = mustParseURL({{ printf "%q" .Default }})
+	fs.Var(urlValue{&config.{{ .Field }}}, "{{ .CLI }}", "{{ .ShortHelp }}")
+	{{- else if eq .Type "time.Time" }}
+	config.{{ .Field }} = mustParseTime({{ printf "%q" .Layout }}, {{ printf "%q" .Default }})
+	fs.Var(timeValue{&config.{{ .Field }}, {{ printf "%q" .Layout }}}, "{{ .CLI }}", "{{ .ShortHelp }}")
+	{{- else if eq .Type "regexp" }}
+	config.{{ .Field }} = mustCompileRegexp({{ printf "%q" .Default }})
+	fs.Var(regexpValue{&config.{{ .Field }}}, "{{ .CLI }}", "{{ .ShortHelp }}")
+	{{- else if eq .Type "custom" }}
+	{{- if .Default }}
+	fs.TextVar(&config.{{ .Field }}, "{{ .CLI }}", mustUnmarshalText[{{ .GoType }}]({{ printf "%q" .Default }}), "{{ .ShortHelp }}")
+	{{- else }}
+	fs.TextVar(&config.{{ .Field }}, "{{ .CLI }}", new({{ .GoType }}), "{{ .ShortHelp }}")
+	{{- end }}
 	{{- end }}
 {{- end }}
-{{- if .FSUsage }}
+{{- if .Sectioned }}
 	fs.Usage = func() {
-		usage("{{ .FSUsage }}")
+	{{- if .FSUsage }}
+		usage(fs.Output(), "{{ .FSUsage }}")
+	{{- else }}
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+	{{- end }}
+	{{- range .Sections }}
+		fmt.Fprintf(fs.Output(), "\n%s:\n", {{ printf "%q" .Name }})
+		printDefaults(fs{{ range .Flags }}, "{{ .CLI }}"{{ end }})
+	{{- end }}
+	}
+{{- else if .FSUsage }}
+	fs.Usage = func() {
+		usage(fs.Output(), "{{ .FSUsage }}")
 		fs.PrintDefaults()
 	}
 {{- end }}
@@ -82,7 +182,31 @@ func Forge(arguments []string) (*flag.FlagSet, *{{ .ConfigType }}, error) {
 {{- end }}
 {{- range $index, $element := .Flags }}
 	{{- if eq .Type "[]string" }}
-	    config.{{ .Name }} = splitString(tmp{{ .Name }}, "{{ .Delimiter }}")
+	    config.{{ .Field }} = splitString({{ .Tmp }}, "{{ .Delimiter }}")
+	{{- end }}
+{{- end }}
+{{- if .Derived }}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+{{- range .Derived }}
+	if !set["{{ .CLI }}"]{{ if eq .GoType "string" }} || config.{{ .Field }} == ""{{ end }} {
+		config.{{ .Field }} = config.{{ .From }}
+	}
+{{- end }}
+{{- end }}
+{{- range .Flags }}
+	{{- if .CheckPath }}
+	if err := checkPath(&config.{{ .Field }}, "{{ .CLI }}", pathOptions{
+		expandHome:   {{ .ExpandHome }},
+		makeAbsolute: {{ .MakeAbsolute }},
+		createDir:    {{ .CreateDir }},
+		mustExist:    {{ .MustExist }},
+		kind:         {{ printf "%q" .Kind }},
+	}); err != nil {
+		return nil, nil, err
+	}
 	{{- end }}
 {{- end }}
 	return fs, config, nil
@@ -107,11 +231,322 @@ func fmtError(msg string) error {
 	return errors.New(msg)
 }
 
-func usage(msg string) {
-	fmt.Fprintf(os.Stderr, "%s", msg)
+func usage(w io.Writer, msg string) {
+	fmt.Fprintf(w, "%s", msg)
+}
+{{- if index .Types "hostport" }}
+
+// hostPortValue is a flag.Value holding an address of the form host:port.
+type hostPortValue string
+
+func (v *hostPortValue) String() string {
+	return string(*v)
+}
+
+func (v *hostPortValue) Set(s string) error {
+	if _, _, err := net.SplitHostPort(s); err != nil {
+		return err
+	}
+	*v = hostPortValue(s)
+	return nil
+}
+{{- end }}
+{{- if or (index .Types "int32") (index .Types "uint32") (index .Types "uint16") }}
+
+// intValue is a flag.Value holding an integer of a type the flag package
+// lacks, rejecting values which overflow it.
+type intValue[T int32 | uint32 | uint16] struct {
+	p *T
+}
+
+func (v intValue[T]) String() string {
+	if v.p == nil {
+		return "0"
+	}
+	return strconv.FormatInt(int64(*v.p), 10)
+}
+
+func (v intValue[T]) Set(s string) error {
+	n, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return err
+	}
+	if int64(T(n)) != n {
+		return errors.New("value out of range")
+	}
+	*v.p = T(n)
+	return nil
+}
+{{- end }}
+{{- if index .Types "counter" }}
+
+// counterValue is a flag.Value counting how often its flag is given, up to max
+// if it is positive. Given a value, as in -v=3, the flag sets the count.
+type counterValue struct {
+	n   *int
+	max int
+}
+
+func (v counterValue) IsBoolFlag() bool {
+	return true
+}
+
+func (v counterValue) String() string {
+	if v.n == nil {
+		return "0"
+	}
+	return strconv.Itoa(*v.n)
+}
+
+func (v counterValue) Set(s string) error {
+	n := *v.n + 1
+	if s != "true" {
+		var err error
+		if n, err = strconv.Atoi(s); err != nil {
+			return err
+		}
+		if n < 0 {
+			return errors.New("value out of range")
+		}
+	}
+	if v.max > 0 && n > v.max {
+		n = v.max
+	}
+	*v.n = n
+	return nil
+}
+{{- end }}
+{{- if or (index .Types "ip") (index .Types "cidr") (index .Types "custom") }}
+
+// mustUnmarshalText returns the value of type T represented by the given text.
+func mustUnmarshalText[T any, P interface {
+	*T
+	encoding.TextUnmarshaler
+}](text string) T {
+	var v T
+	if err := P(&v).UnmarshalText([]byte(text)); err != nil {
+		panic(err)
+	}
+	return v
+}
+{{- end }}
+{{- if index .Types "url" }}
+
+// urlValue is a flag.Value holding a URL.
+type urlValue struct {
+	u **url.URL
+}
+
+func (v urlValue) String() string {
+	if v.u == nil || *v.u == nil {
+		return ""
+	}
+	return (*v.u).String()
+}
+
+func (v urlValue) Set(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	*v.u = u
+	return nil
+}
+
+// mustParseURL parses the given URL, returning nil if it is empty.
+func mustParseURL(s string) *url.URL {
+	if s == "" {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+{{- end }}
+{{- if index .Types "time.Time" }}
+
+// timeValue is a flag.Value holding a time in the given layout.
+type timeValue struct {
+	t      *time.Time
+	layout string
+}
+
+func (v timeValue) String() string {
+	if v.t == nil || v.t.IsZero() {
+		return ""
+	}
+	return v.t.Format(v.layout)
+}
+
+func (v timeValue) Set(s string) error {
+	t, err := time.Parse(v.layout, s)
+	if err != nil {
+		return err
+	}
+	*v.t = t
+	return nil
+}
+
+// mustParseTime parses the given time in the given layout, returning the zero
+// time if it is empty.
+func mustParseTime(layout, s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+{{- end }}
+{{- if index .Types "regexp" }}
+
+// regexpValue is a flag.Value holding a regular expression.
+type regexpValue struct {
+	re **regexp.Regexp
+}
+
+func (v regexpValue) String() string {
+	if v.re == nil || *v.re == nil {
+		return ""
+	}
+	return (*v.re).String()
+}
+
+func (v regexpValue) Set(s string) error {
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return err
+	}
+	*v.re = re
+	return nil
+}
+
+// mustCompileRegexp compiles the given regular expression, returning nil if it
+// is empty.
+func mustCompileRegexp(s string) *regexp.Regexp {
+	if s == "" {
+		return nil
+	}
+	return regexp.MustCompile(s)
+}
+{{- end }}
+{{- if .CheckPaths }}
+
+// pathOptions are the checks and changes applied to a filepath flag's value.
+type pathOptions struct {
+	expandHome   bool
+	makeAbsolute bool
+	createDir    bool
+	mustExist    bool
+	kind         string
+}
+
+// checkPath applies the given options to the path set by the named flag,
+// unless it is empty.
+func checkPath(path *string, name string, opts pathOptions) error {
+	if *path == "" {
+		return nil
+	}
+	if opts.expandHome && (*path == "~" || strings.HasPrefix(*path, "~/")) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("flag -%s: %w", name, err)
+		}
+		*path = filepath.Join(home, (*path)[1:])
+	}
+	if opts.makeAbsolute {
+		abs, err := filepath.Abs(*path)
+		if err != nil {
+			return fmt.Errorf("flag -%s: %w", name, err)
+		}
+		*path = abs
+	}
+	if opts.createDir {
+		dir := *path
+		if opts.kind == "file" {
+			dir = filepath.Dir(dir)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("flag -%s: failed to create directory: %w", name, err)
+		}
+	}
+
+	info, err := os.Stat(*path)
+	if errors.Is(err, os.ErrNotExist) && !opts.mustExist {
+		return nil
+	} else if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("flag -%s: %s does not exist", name, *path)
+	} else if err != nil {
+		return fmt.Errorf("flag -%s: %w", name, err)
+	}
+	if opts.kind == "file" && !info.Mode().IsRegular() {
+		return fmt.Errorf("flag -%s: %s is not a file", name, *path)
+	}
+	if opts.kind == "dir" && !info.IsDir() {
+		return fmt.Errorf("flag -%s: %s is not a directory", name, *path)
+	}
+	if opts.mustExist {
+		f, err := os.Open(*path)
+		if err != nil {
+			return fmt.Errorf("flag -%s: %s is not readable: %w", name, *path, err)
+		}
+		f.Close()
+	}
+	return nil
+}
+{{- end }}
+{{- define "field" }}
+	// {{ .ShortHelp }}
+	{{- if eq .Type "filepath" }}
+	{{ .Name }} string ` + "`filepath:\"true\"`" + `
+	{{- else }}
+	{{ .Name }} {{ .GoType }}
+	{{- end }}
+{{- end }}
+{{- if .Sectioned }}
+
+// printDefaults prints the named flags, in the order given, in the format used
+// by flag.PrintDefaults.
+func printDefaults(fs *flag.FlagSet, names ...string) {
+	for _, name := range names {
+		f := fs.Lookup(name)
+		one := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+		one.SetOutput(fs.Output())
+		one.Var(f.Value, f.Name, f.Usage)
+		one.Lookup(f.Name).DefValue = f.DefValue
+		one.PrintDefaults()
+	}
 }
+{{- end }}
 `
 
+// markdownSectionTemplate renders a single section: an optional heading
+// followed by a table of that section's flags.
+const markdownSectionTemplate = `{{ if .Name }}## {{ .Name }}
+
+{{ end }}
+{{- range .Columns }}| {{ columnHeading . }} {{ end }}|
+{{ range .Columns }}|-{{ end }}|
+{{ range .Flags }}{{ $flag := . }}
+{{- range $.Columns }}|
+{{- if eq . "flag" }}{{ escapeMarkdown $flag.CLI }}
+	{{- with $flag.Since }}<br>*Since {{ escapeMarkdown . }}*{{ end }}
+{{- else if eq . "usage" }}{{ escapeMarkdown $flag.ShortHelp }}
+	{{- with trimSpace $flag.LongHelp }}{{ if not (hasSuffix $flag.ShortHelp ".") }}.{{ end }} {{ escapeMarkdown . }}{{ end }}
+	{{- with notes $flag }}{{ if not (or (trimSpace $flag.LongHelp) (hasSuffix $flag.ShortHelp ".")) }}.{{ end }} {{ escapeMarkdown . }}{{ end }}
+	{{- with examples $flag }}<br><br>Example{{ if gt (len .) 1 }}s{{ end }}:
+	{{- range $i, $e := . }}{{ if $i }},{{ end }} ` + "`{{ escapeMarkdown $e }}`" + `{{ end }}
+	{{- end }}
+{{- else if eq . "section" }}{{ escapeMarkdown $flag.Section }}
+{{- else }}` + "`{{ cell $flag . | escapeMarkdown }}`" + `
+{{- end }}
+{{- end }}|
+{{ end }}`
+
 // htmlSectionTemplate renders a single section: an optional Markdown heading
 // followed by a table of that section's flags. The output is a fragment, not a
 // complete HTML document, so that it can be embedded directly in a page which
@@ -121,22 +556,67 @@ func usage(msg string) {
 // in the page's table of contents.
 const htmlSectionTemplate = `{{ if .Name }}## {{ .Name }}
 
-{{ end }}<table class="rq-flags">
+{{ end }}{{ template "table" . }}`
+
+// htmlTableTemplate renders a section's table of flags. It is shared by the
+// HTML fragment and the standalone HTML page, which alone anchors each row.
+const htmlTableTemplate = `{{ define "table" }}<table class="rq-flags">
 	<tr>
-		<th class="col-cli">Flag</th>
-		<th class="col-usage">Usage</th>
+	{{- range .Columns }}
+		<th class="col-{{ columnClass . }}">{{ columnHeading . }}</th>
+	{{- end }}
 	</tr>
 	{{- range .Flags }}
-	<tr>
-		<td><code>-{{ .CLI | html }}</code></td>
-		<td>{{ .ShortHelp | html }}.
-		{{- if .LongHelp }}
-		    <br><br>{{ .LongHelp | html }}
+	{{- $flag := . }}
+	<tr{{ if $.Anchors }} id="{{ flagID $flag }}"{{ end }}>
+	{{- range $.Columns }}
+	{{- if eq . "flag" }}
+		<td>{{ if $.Anchors }}<a href="#{{ flagID $flag }}">{{ end }}<code>-{{ $flag.CLI | html }}</code>{{ if $.Anchors }}</a>{{ end }}
+		{{- if $flag.Since }}<br><span class="rq-since">Since {{ $flag.Since | html }}</span>{{ end }}</td>
+	{{- else if eq . "usage" }}
+		<td>{{ $flag.ShortHelp | html }}.
+		{{- if and $flag.LongHelp $.MarkdownHelp }}
+		    {{ markdown $flag.LongHelp }}
+		{{- else if $flag.LongHelp }}
+		    <br><br>{{ $flag.LongHelp | html }}
+		{{- end }}
+		{{- with notes $flag }}
+		    <br><br>{{ . | html }}
+		{{- end }}
+		{{- with examples $flag }}
+		    <br><br>{{ if gt (len .) 1 }}Examples{{ else }}Example{{ end }}:
+		    {{- range $i, $e := . }}{{ if $i }},{{ end }} <code>{{ $e | html }}</code>{{ end }}
 		{{- end }}</td>
+	{{- else if eq . "section" }}
+		<td>{{ $flag.Section | html }}</td>
+	{{- else }}
+		<td><code>{{ cell $flag . | html }}</code></td>
+	{{- end }}
+	{{- end }}
 	</tr>
 	{{- end }}
 </table>
-`
+{{ end }}`
+
+// Documentation columns. The flag and usage columns are shown by default.
+const (
+	columnFlag    = "flag"
+	columnUsage   = "usage"
+	columnType    = "type"
+	columnDefault = "default"
+	columnEnv     = "env"
+	columnSection = "section"
+)
+
+// columnHeadings maps each documentation column to its heading.
+var columnHeadings = map[string]string{
+	columnFlag:    "Flag",
+	columnUsage:   "Usage",
+	columnType:    "Type",
+	columnDefault: "Default",
+	columnEnv:     "Environment variable",
+	columnSection: "Section",
+}
 
 // Format represents the output format of the generator.
 type Format int
@@ -145,6 +625,14 @@ const (
 	Go Format = iota
 	Markdown
 	HTML
+	JSONSchema
+	SampleTOML
+	SampleYAML
+	HelmValues
+	HelmArgs
+	SystemdEnv
+	SystemdExecStart
+	HTMLPage
 )
 
 // String returns the string representation of the format.
@@ -156,12 +644,55 @@ func (f Format) String() string {
 		return "Markdown"
 	case HTML:
 		return "HTML"
+	case JSONSchema:
+		return "JSONSchema"
+	case SampleTOML:
+		return "SampleTOML"
+	case SampleYAML:
+		return "SampleYAML"
+	case HelmValues:
+		return "HelmValues"
+	case HelmArgs:
+		return "HelmArgs"
+	case SystemdEnv:
+		return "SystemdEnv"
+	case SystemdExecStart:
+		return "SystemdExecStart"
+	case HTMLPage:
+		return "HTMLPage"
 	default:
 		return "Unknown"
 	}
 }
 
-// Generator represents a flag, HTML, or Markdown generator.
+// formatNames maps the name of each format, as given on the command line and
+// in an output's format key, to the format.
+var formatNames = map[string]Format{
+	"go":           Go,
+	"markdown":     Markdown,
+	"html":         HTML,
+	"html-page":    HTMLPage,
+	"jsonschema":   JSONSchema,
+	"sample-toml":  SampleTOML,
+	"sample-yaml":  SampleYAML,
+	"helm-values":  HelmValues,
+	"helm-args":    HelmArgs,
+	"systemd-env":  SystemdEnv,
+	"systemd-exec": SystemdExecStart,
+}
+
+// ParseFormat returns the format with the given name, such as "go" or
+// "html-page".
+func ParseFormat(name string) (Format, error) {
+	f, ok := formatNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown format: %s", name)
+	}
+	return f, nil
+}
+
+// Generator represents a flag, HTML, Markdown, JSON Schema, sample
+// configuration file, Helm chart, or systemd unit generator.
 type Generator struct {
 	pkg            string
 	configTypeName string
@@ -169,23 +700,89 @@ type Generator struct {
 	flagSetUsage         string
 	flagSetName          string
 	flagSetErrorHandling string
+	nestSections         bool
+
+	docs    DocsConfig
+	helm    HelmConfig
+	env     EnvConfig
+	systemd SystemdConfig
 
-	args  []Argument
-	flags []Flag
+	args     []Argument
+	flags    []Flag
+	sections []Section
+
+	// templates overrides the built-in templates, by format.
+	templates map[Format]string
 }
 
 // NewGenerator creates a new generator with the given package name, name, and
 // path to the TOML configuration file.
 func NewGenerator(cfg *ParsedConfig) (*Generator, error) {
-	return &Generator{
+	if err := checkArgs(cfg.Arguments); err != nil {
+		return nil, err
+	}
+	if err := checkFlags(cfg.Flags); err != nil {
+		return nil, err
+	}
+
+	g := &Generator{
 		pkg:                  cfg.GoConfig.Package,
 		configTypeName:       cfg.GoConfig.ConfigTypeName,
 		flagSetUsage:         cfg.GoConfig.FlagSetUsage,
 		flagSetName:          cfg.GoConfig.FlagSetName,
 		flagSetErrorHandling: cfg.GoConfig.FlagErrorHandling,
+		nestSections:         cfg.GoConfig.NestSections,
+		docs:                 cfg.DocsConfig,
+		helm:                 cfg.HelmConfig,
+		env:                  cfg.EnvConfig,
+		systemd:              cfg.SystemdConfig,
 		args:                 cfg.Arguments,
 		flags:                cfg.Flags,
-	}, nil
+		sections:             cfg.Sections,
+	}
+
+	for f, path := range map[Format]string{
+		Go:       cfg.Templates.Go,
+		Markdown: cfg.Templates.Markdown,
+		HTML:     cfg.Templates.HTML,
+	} {
+		if path == "" {
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s template: %w", f, err)
+		}
+		if err := g.SetTemplate(f, string(b)); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// SetTemplate replaces the built-in template for the given format, which must
+// be Go, Markdown or HTML, with the given text/template. The template receives
+// the same data as the built-in one: for Go the whole flag set, and for
+// Markdown and HTML each section in turn.
+func (g *Generator) SetTemplate(f Format, text string) error {
+	switch f {
+	case Go, Markdown, HTML:
+	default:
+		return fmt.Errorf("format %s does not support templates", f)
+	}
+	if g.templates == nil {
+		g.templates = make(map[Format]string)
+	}
+	g.templates[f] = text
+	return nil
+}
+
+// template returns the template set for the given format, or def if none is.
+func (g *Generator) template(f Format, def string) string {
+	if text, ok := g.templates[f]; ok {
+		return text
+	}
+	return def
 }
 
 // Execute generates the output in the given format and writes it to the given
@@ -198,6 +795,22 @@ func (g *Generator) Execute(f Format, w io.Writer) error {
 		return g.doMarkdown(w)
 	case HTML:
 		return g.doHTML(w)
+	case JSONSchema:
+		return g.doJSONSchema(w)
+	case SampleTOML:
+		return g.doSample(w, " = ")
+	case SampleYAML:
+		return g.doSample(w, ": ")
+	case HelmValues:
+		return g.doHelmValues(w)
+	case HelmArgs:
+		return g.doHelmArgs(w)
+	case SystemdEnv:
+		return g.doSystemdEnv(w)
+	case SystemdExecStart:
+		return g.doSystemdExecStart(w)
+	case HTMLPage:
+		return g.doHTMLPage(w)
 	default:
 		return fmt.Errorf("unsupported format: %s", f)
 	}
@@ -205,16 +818,18 @@ func (g *Generator) Execute(f Format, w io.Writer) error {
 
 func (g *Generator) doGo(w io.Writer) error {
 	// Parse the template.
-	tmpl, err := template.New("flags").Parse(flagTemplate)
+	tmpl, err := template.New("flags").Parse(g.template(Go, flagTemplate))
 	if err != nil {
 		return fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	// Perform some checks of the flags.
-	for i, flag := range g.flags {
+	// Perform some checks of the flags, normalizing a copy of them so that
+	// other formats generated from the same configuration see them as written.
+	normalized := slices.Clone(g.flags)
+	for i, flag := range normalized {
 		if flag.Type == "time.Duration" {
 			if flag.Default == nil {
-				g.flags[i].Default = 0
+				normalized[i].Default = "0"
 			} else {
 				s, ok := flag.Default.(string)
 				if !ok {
@@ -225,34 +840,155 @@ func (g *Generator) doGo(w io.Writer) error {
 				}
 			}
 		}
+		if flag.Type == "time.Time" {
+			// Timestamps may be given as TOML or YAML values of their own,
+			// and so are always written in the flag's layout.
+			if flag.Layout == "" {
+				normalized[i].Layout = time.RFC3339
+			}
+			def, err := flagDefault(normalized[i])
+			if err != nil {
+				return err
+			}
+			normalized[i].Default = def
+		}
 		if flag.Type == "[]string" {
 			if flag.Delimiter == "" {
-				g.flags[i].Delimiter = ","
+				normalized[i].Delimiter = ","
 			}
 			if flag.Default == nil {
-				g.flags[i].Default = ""
+				normalized[i].Default = ""
+			}
+		}
+		switch flag.Type {
+		case "string", "filepath", "bool", "int", "int32", "int64", "uint", "uint16", "uint32", "uint64", "float64", "counter",
+			"hostport", "ip", "cidr", "url", "regexp":
+			// Defaults are written as read from any input format, where
+			// integers may have been given as floats.
+			def, err := flagDefault(flag)
+			if err != nil {
+				return err
+			}
+			normalized[i].Default = def
+		}
+	}
+
+	// Sections, if declared, group the flags in the usage message, and in
+	// nested mode also in the config struct.
+	sections, err := g.goSections(normalized)
+	if err != nil {
+		return err
+	}
+	sectioned := sections[0].Name != ""
+	if g.nestSections && !sectioned {
+		return fmt.Errorf("nest_sections is set but no flag declares a section")
+	}
+	// Flags are keyed by command-line name, as only that is unique when
+	// sections are nested.
+	fields := make(map[string]string)
+	for _, section := range sections {
+		for _, flag := range section.Flags {
+			fields[flag.CLI] = flag.Field
+		}
+	}
+	flags := make([]goFlag, len(normalized))
+	checkPaths := false
+	types := make(map[string]bool)
+	tmps := make(map[string]bool)
+	for i, flag := range normalized {
+		flags[i] = newGoFlag(flag, fields[flag.CLI])
+		checkPaths = checkPaths || flags[i].CheckPath
+		types[flag.Type] = true
+		if flag.Type == "[]string" {
+			// Fields' paths are unique, but run together they might not be.
+			base := "tmp" + strings.ReplaceAll(flags[i].Field, ".", "")
+			tmp := base
+			for n := 2; tmps[tmp]; n++ {
+				tmp = fmt.Sprintf("%s%d", base, n)
 			}
+			tmps[tmp] = true
+			flags[i].Tmp = tmp
 		}
 	}
 
+	// Flags which take their default from others are filled in after
+	// parsing, each after the flag it takes its default from.
+	var derived []goDerived
+	ordered, err := defaultFromOrder(g.flags)
+	if err != nil {
+		return err
+	}
+	for _, flag := range ordered {
+		from := findFlag(g.flags, flag.DefaultFrom)
+		derived = append(derived, goDerived{
+			goFlag: newGoFlag(flag, fields[flag.CLI]),
+			From:   fields[from.CLI],
+		})
+	}
+
+	// Import only what the generated code uses.
+	imports := []string{"errors", "flag", "fmt", "io", "os", "strings", "time"}
+	if checkPaths {
+		imports = append(imports, "path/filepath")
+	}
+	if types["hostport"] {
+		imports = append(imports, "net")
+	}
+	if types["int32"] || types["uint32"] || types["uint16"] || types["counter"] {
+		imports = append(imports, "strconv")
+	}
+	if types["ip"] || types["cidr"] {
+		imports = append(imports, "encoding", "net/netip")
+	}
+	if types["custom"] {
+		imports = append(imports, "encoding")
+	}
+	if types["url"] {
+		imports = append(imports, "net/url")
+	}
+	if types["regexp"] {
+		imports = append(imports, "regexp")
+	}
+	for _, flag := range normalized {
+		if flag.Import != "" {
+			imports = append(imports, flag.Import)
+		}
+	}
+	slices.Sort(imports)
+	imports = slices.Compact(imports)
+
 	// Execute the template with the flags data.
 	var output bytes.Buffer
 	if err := tmpl.Execute(&output, struct {
 		Pkg             string
+		Imports         []string
 		FSUsage         string
 		FSName          string
 		FSErrorHandling string
 		ConfigType      string
 		Args            []Argument
-		Flags           []Flag
+		Flags           []goFlag
+		Sections        []goSection
+		Sectioned       bool
+		Nested          bool
+		CheckPaths      bool
+		Types           map[string]bool
+		Derived         []goDerived
 	}{
 		Pkg:             g.pkg,
+		Imports:         imports,
 		FSUsage:         g.flagSetUsage,
 		FSName:          g.flagSetName,
 		FSErrorHandling: g.flagSetErrorHandling,
 		ConfigType:      g.configTypeName,
 		Args:            g.args,
-		Flags:           g.flags,
+		Flags:           flags,
+		Sections:        sections,
+		Sectioned:       sectioned,
+		Nested:          g.nestSections,
+		CheckPaths:      checkPaths,
+		Types:           types,
+		Derived:         derived,
 	}); err != nil {
 		return fmt.Errorf("failed to execute template: %w", err)
 	}
@@ -269,76 +1005,488 @@ func (g *Generator) doGo(w io.Writer) error {
 }
 
 func (g *Generator) doMarkdown(w io.Writer) error {
+	return g.doSections(Markdown, markdownSectionTemplate, w)
+}
+
+func (g *Generator) doHTML(w io.Writer) error {
+	return g.doSections(HTML, htmlSectionTemplate, w)
+}
+
+// doSections executes the template for the given documentation format once
+// per section, separating each from the last with a blank line.
+func (g *Generator) doSections(f Format, text string, w io.Writer) error {
 	sections, err := groupBySection(g.flags)
 	if err != nil {
 		return err
 	}
 
+	tmpl, err := g.parseDocTemplate(g.template(f, text))
+	if err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
 	for i, section := range sections {
-		builder := strings.Builder{}
 		if i > 0 {
-			builder.WriteString("\n")
+			output.WriteString("\n")
 		}
-		if section.Name != "" {
-			builder.WriteString(fmt.Sprintf("## %s\n\n", section.Name))
+		if err := tmpl.Execute(&output, g.docSection(section, false)); err != nil {
+			return fmt.Errorf("failed to execute %s template: %w", f, err)
 		}
+	}
 
-		// Write the markdown table header.
-		builder.WriteString("| Flag | Usage |\n|-|-|\n")
+	if _, err := w.Write(output.Bytes()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f, err)
+	}
+	return nil
+}
 
-		// Write each flag as a row in the table.
-		for _, flag := range section.Flags {
-			builder.WriteString("|")
-			builder.WriteString(escapeMarkdown(flag.CLI))
-			builder.WriteString("|")
-			builder.WriteString(escapeMarkdown(flag.ShortHelp))
-			if flag.Default != nil {
-				if !strings.HasSuffix(flag.ShortHelp, ".") {
-					builder.WriteString(".")
-				}
-				builder.WriteString(fmt.Sprintf(" %s", escapeMarkdown(flag.LongHelp)))
+// parseDocTemplate parses the given documentation template, together with the
+// HTML table template, which it may use or redefine.
+func (g *Generator) parseDocTemplate(text string) (*template.Template, error) {
+	if err := g.checkColumns(); err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New("docs").Funcs(template.FuncMap{
+		"html": func(s string) string {
+			return template.HTMLEscapeString(s)
+		},
+		"examples":      flagExamples,
+		"columnHeading": func(c string) string { return columnHeadings[c] },
+		"columnClass": func(c string) string {
+			// The flag column's class predates configurable columns.
+			if c == columnFlag {
+				return "cli"
 			}
-			builder.WriteString("|\n")
+			return c
+		},
+		"cell":           g.cell,
+		"markdown":       renderMarkdown,
+		"escapeMarkdown": escapeMarkdown,
+		"notes":          flagNotes,
+		"trimSpace":      strings.TrimSpace,
+		"hasSuffix":      strings.HasSuffix,
+		"flagID":         flagID,
+		"sectionID":      sectionID,
+	}).Parse(htmlTableTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML table template: %w", err)
+	}
+	if _, err := tmpl.Parse(text); err != nil {
+		return nil, fmt.Errorf("failed to parse documentation template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// docSection returns the given section as seen by the HTML templates.
+func (g *Generator) docSection(s section, anchors bool) docSection {
+	return docSection{
+		section:      s,
+		Columns:      g.docs.Columns,
+		MarkdownHelp: g.docs.MarkdownHelp,
+		Anchors:      anchors,
+	}
+}
+
+// checkColumns returns an error if an unknown documentation column is
+// configured.
+func (g *Generator) checkColumns() error {
+	for _, c := range g.docs.Columns {
+		if _, ok := columnHeadings[c]; !ok {
+			return fmt.Errorf("unknown documentation column %q", c)
 		}
-		if _, err := w.Write([]byte(builder.String())); err != nil {
-			return err
+	}
+	return nil
+}
+
+// cell returns the plain text of the given flag's cell in the given type,
+// default, or environment variable column.
+func (g *Generator) cell(flag Flag, column string) (string, error) {
+	switch column {
+	case columnType:
+		return typeName(flag), nil
+	case columnDefault:
+		return formatDefault(flag)
+	case columnEnv:
+		return g.envName(flag.CLI), nil
+	default:
+		return "", fmt.Errorf("column %q has no plain text cells", column)
+	}
+}
+
+// typeName returns the name of the given flag's type in the documentation.
+func typeName(flag Flag) string {
+	if flag.Type != "custom" {
+		return flag.Type
+	}
+	if flag.TypeDoc != "" {
+		return flag.TypeDoc
+	}
+	return flag.GoType
+}
+
+// formatDefault returns the default value of the given flag as it would be
+// given on the command line. An empty default is shown as "".
+func formatDefault(flag Flag) (string, error) {
+	if flag.DefaultFrom != "" {
+		return "same as -" + strings.TrimLeft(flag.DefaultFrom, "-"), nil
+	}
+	def, err := flagDefault(flag)
+	if err != nil {
+		return "", err
+	}
+	s := fmt.Sprint(def)
+	if list, ok := def.([]string); ok {
+		delim := flag.Delimiter
+		if delim == "" {
+			delim = ","
+		}
+		s = strings.Join(list, delim)
+	}
+	if s == "" {
+		return `""`, nil
+	}
+	return s, nil
+}
+
+// goFlag is a flag as seen by the Go template.
+type goFlag struct {
+	Flag
+
+	// Field is the path to the flag's field within the config struct.
+	Field string
+
+	// GoType is the type of the flag's field.
+	GoType string
+
+	// CheckPath is whether the flag's path is checked after parsing.
+	CheckPath bool
+
+	// Tmp is the name of the variable holding the unsplit value of a
+	// []string flag.
+	Tmp string
+}
+
+// goDerived is a flag which takes its default from another, as seen by the Go
+// template.
+type goDerived struct {
+	goFlag
+
+	// From is the path to the other flag's field within the config struct.
+	From string
+}
+
+// goTypes maps each flag type whose field is not of the same name to the type
+// of its field.
+var goTypes = map[string]string{
+	"filepath": "string",
+	"hostport": "string",
+	"ip":       "netip.Addr",
+	"cidr":     "netip.Prefix",
+	"url":      "*url.URL",
+	"regexp":   "*regexp.Regexp",
+	"counter":  "int",
+}
+
+// newGoFlag returns the given flag as seen by the Go template, with the given
+// path to its field.
+func newGoFlag(flag Flag, field string) goFlag {
+	goType, ok := goTypes[flag.Type]
+	if !ok {
+		goType = flag.Type
+	}
+	if flag.Type == "custom" {
+		goType = flag.GoType
+	}
+	return goFlag{
+		Flag:      flag,
+		Field:     field,
+		GoType:    goType,
+		CheckPath: hasPathOptions(flag),
+	}
+}
+
+// hasPathOptions returns whether the given flag sets any of the options of a
+// filepath flag.
+func hasPathOptions(flag Flag) bool {
+	return flag.ExpandHome || flag.MakeAbsolute || flag.CreateDir || flag.MustExist || flag.Kind != ""
+}
+
+// flagNotes describes, for the documentation, where the given flag's default
+// comes from if not from its default, and any requirements of its value.
+func flagNotes(flag Flag) string {
+	var notes []string
+	if flag.DefaultFrom != "" {
+		notes = append(notes, fmt.Sprintf("Defaults to the value of -%s.", strings.TrimLeft(flag.DefaultFrom, "-")))
+	}
+	if flag.Type == "counter" {
+		if flag.Max > 0 {
+			notes = append(notes, fmt.Sprintf("Counts how often it is given, up to %d.", flag.Max))
+		} else {
+			notes = append(notes, "Counts how often it is given.")
+		}
+	}
+	if flag.Type == "time.Time" {
+		layout := flag.Layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		notes = append(notes, fmt.Sprintf("Given in the layout %s.", layout))
+	}
+	if reqs := pathRequirements(flag); reqs != "" {
+		notes = append(notes, reqs)
+	}
+	return strings.Join(notes, " ")
+}
+
+// pathRequirements describes, for the documentation, the options set on the
+// given filepath flag.
+func pathRequirements(flag Flag) string {
+	var reqs []string
+	if flag.ExpandHome {
+		reqs = append(reqs, "A leading ~ is expanded to the home directory.")
+	}
+	if flag.MakeAbsolute {
+		reqs = append(reqs, "A relative path is made absolute.")
+	}
+	if flag.CreateDir && flag.Kind == "file" {
+		reqs = append(reqs, "Its directory is created if it doesn't exist.")
+	} else if flag.CreateDir {
+		reqs = append(reqs, "Created if it doesn't exist.")
+	}
+	kind := map[string]string{"file": "file", "dir": "directory"}[flag.Kind]
+	switch {
+	case flag.MustExist && kind != "":
+		reqs = append(reqs, fmt.Sprintf("Must be an existing, readable %s.", kind))
+	case flag.MustExist:
+		reqs = append(reqs, "Must exist and be readable.")
+	case kind != "":
+		reqs = append(reqs, fmt.Sprintf("Must be a %s, if it exists.", kind))
+	}
+	return strings.Join(reqs, " ")
+}
+
+// checkArgs returns an error if an argument is of a type which the generated
+// code can't parse.
+func checkArgs(args []Argument) error {
+	for _, arg := range args {
+		if arg.Type != "string" {
+			return fmt.Errorf("argument %s has type %q, must be string", arg.Name, arg.Type)
 		}
 	}
 	return nil
 }
 
-func (g *Generator) doHTML(w io.Writer) error {
-	sections, err := groupBySection(g.flags)
+// checkFlags returns an error if a flag sets options which don't apply to it.
+func checkFlags(flags []Flag) error {
+	for _, flag := range flags {
+		if _, err := flagDefault(flag); err != nil {
+			return err
+		}
+		if hasPathOptions(flag) && flag.Type != "filepath" {
+			return fmt.Errorf("flag %s sets filesystem options but is of type %s, not filepath", flag.Name, flag.Type)
+		}
+		switch flag.Kind {
+		case "", "file", "dir":
+		default:
+			return fmt.Errorf("flag %s has kind %q, must be file or dir", flag.Name, flag.Kind)
+		}
+		if flag.Max != 0 && flag.Type != "counter" {
+			return fmt.Errorf("flag %s sets max but is of type %s, not counter", flag.Name, flag.Type)
+		}
+		if flag.Max < 0 {
+			return fmt.Errorf("counter flag %s has negative max", flag.Name)
+		}
+		if flag.Layout != "" && flag.Type != "time.Time" {
+			return fmt.Errorf("flag %s sets layout but is of type %s, not time.Time", flag.Name, flag.Type)
+		}
+		if err := checkCustom(flag); err != nil {
+			return err
+		}
+	}
+	_, err := defaultFromOrder(flags)
+	return err
+}
+
+// checkCustom returns an error if the given flag sets go_type, import or
+// type_doc but isn't custom, or is custom but its go_type isn't the name of a
+// type which isn't a pointer.
+func checkCustom(flag Flag) error {
+	if flag.Type != "custom" {
+		if flag.GoType != "" || flag.Import != "" || flag.TypeDoc != "" {
+			return fmt.Errorf("flag %s sets go_type, import or type_doc but is of type %s, not custom", flag.Name, flag.Type)
+		}
+		return nil
+	}
+	if flag.GoType == "" {
+		return fmt.Errorf("custom flag %s has no go_type", flag.Name)
+	}
+	expr, err := parser.ParseExpr(flag.GoType)
 	if err != nil {
-		return err
+		return fmt.Errorf("custom flag %s has invalid go_type %q: %v", flag.Name, flag.GoType, err)
+	}
+	switch expr.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr:
+		return nil
+	default:
+		return fmt.Errorf("custom flag %s has go_type %q, must be a named type, not a pointer", flag.Name, flag.GoType)
 	}
+}
 
-	// Parse the template.
-	tmpl, err := template.New("htmlTable").Funcs(template.FuncMap{
-		"html": func(s string) string {
-			return template.HTMLEscapeString(s)
-		},
-	}).Parse(htmlSectionTemplate)
+// findFlag returns the flag with the given command-line name, which may be
+// given with or without its leading dash, or nil if there is none.
+func findFlag(flags []Flag, cli string) *Flag {
+	for i := range flags {
+		if strings.TrimLeft(flags[i].CLI, "-") == strings.TrimLeft(cli, "-") {
+			return &flags[i]
+		}
+	}
+	return nil
+}
+
+// defaultFromOrder returns the flags which take their default from another,
+// ordered so that each comes after any flag it takes its default from. An
+// error is returned if a flag takes its default from one which doesn't exist,
+// is of another type, or, directly or not, from itself.
+func defaultFromOrder(flags []Flag) ([]Flag, error) {
+	for _, flag := range flags {
+		if flag.DefaultFrom == "" {
+			continue
+		}
+		if flag.Default != nil {
+			return nil, fmt.Errorf("flag %s sets both default and default_from", flag.Name)
+		}
+		from := findFlag(flags, flag.DefaultFrom)
+		if from == nil {
+			return nil, fmt.Errorf("flag %s takes its default from unknown flag -%s", flag.Name, strings.TrimLeft(flag.DefaultFrom, "-"))
+		}
+		if from.Type != flag.Type || from.GoType != flag.GoType {
+			return nil, fmt.Errorf("flag %s of type %s takes its default from flag %s of type %s",
+				flag.Name, typeName(flag), from.Name, typeName(*from))
+		}
+	}
+
+	// Visit the flags depth first, appending each once those it takes its
+	// default from have been, and tracking the path, by command-line name,
+	// to detect cycles.
+	var (
+		ordered []Flag
+		done    = make(map[string]bool)
+		path    []string
+	)
+	var visit func(flag Flag) error
+	visit = func(flag Flag) error {
+		cli := "-" + strings.TrimLeft(flag.CLI, "-")
+		if done[cli] || flag.DefaultFrom == "" {
+			return nil
+		}
+		if i := slices.Index(path, cli); i >= 0 {
+			return fmt.Errorf("default_from cycle: %s -> %s", strings.Join(path[i:], " -> "), cli)
+		}
+		path = append(path, cli)
+		if err := visit(*findFlag(flags, flag.DefaultFrom)); err != nil {
+			return err
+		}
+		path = path[:len(path)-1]
+		done[cli] = true
+		ordered = append(ordered, flag)
+		return nil
+	}
+	for _, flag := range flags {
+		if err := visit(flag); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// goSection is a section as seen by the Go template. Field and Type, the name
+// of the section's field in the config struct and of that field's struct type,
+// are only used when sections are nested.
+type goSection struct {
+	Name  string
+	Field string
+	Type  string
+	Flags []goFlag
+}
+
+// goSections groups the given flags by section, and resolves the names each
+// section and flag are given in the generated Go code.
+func (g *Generator) goSections(flags []Flag) ([]goSection, error) {
+	sections, err := groupBySection(flags)
 	if err != nil {
-		return fmt.Errorf("failed to parse HTML template: %w", err)
+		return nil, err
 	}
 
-	// Execute the template once per section, separating each from the last with
-	// a blank line.
-	var output bytes.Buffer
-	for i, section := range sections {
-		if i > 0 {
-			output.WriteString("\n")
+	goNames := make(map[string]string)
+	for _, s := range g.sections {
+		goNames[s.Name] = s.GoName
+	}
+	var goSections []goSection
+	owners := make(map[string]string)
+	clis := make(map[string]string)
+	for _, s := range sections {
+		gs := goSection{Name: s.Name}
+		if g.nestSections && s.Name != "" {
+			gs.Field = goNames[s.Name]
+			if gs.Field == "" {
+				gs.Field = goIdentifier(s.Name)
+			}
+			if gs.Field == "" {
+				return nil, fmt.Errorf("section %q has no Go name, set one with go_name", s.Name)
+			}
+			if owner, ok := owners[gs.Field]; ok {
+				return nil, fmt.Errorf("sections %q and %q have the same Go name %s", owner, s.Name, gs.Field)
+			}
+			owners[gs.Field] = s.Name
+			gs.Type = gs.Field + g.configTypeName
+		}
+		for _, flag := range s.Flags {
+			field := flag.Name
+			if gs.Field != "" {
+				field = gs.Field + "." + flag.Name
+			}
+			// A flag's name need only be unique within the struct holding
+			// its field.
+			cli := strings.TrimLeft(flag.CLI, "-")
+			if other, ok := clis[field]; ok {
+				if gs.Field != "" {
+					return nil, fmt.Errorf("flags -%s and -%s of section %q have the same name %s", other, cli, s.Name, flag.Name)
+				}
+				return nil, fmt.Errorf("flags -%s and -%s have the same name %s", other, cli, flag.Name)
+			}
+			clis[field] = cli
+			gs.Flags = append(gs.Flags, newGoFlag(flag, field))
 		}
-		if err := tmpl.Execute(&output, section); err != nil {
-			return fmt.Errorf("failed to execute HTML template: %w", err)
+		goSections = append(goSections, gs)
+	}
+	for _, s := range g.sections {
+		if !slices.ContainsFunc(sections, func(gs section) bool { return gs.Name == s.Name }) {
+			return nil, fmt.Errorf("section %q is declared but no flag belongs to it", s.Name)
 		}
 	}
+	return goSections, nil
+}
 
-	if _, err := w.Write(output.Bytes()); err != nil {
-		return fmt.Errorf("failed to write HTML: %w", err)
+// goIdentifier derives an exported Go identifier from the given name, by
+// capitalizing each of its words and discarding everything else. "HTTP API",
+// for example, becomes "HTTPAPI". An empty string is returned if the result
+// would not be a valid identifier.
+func goIdentifier(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		r, n := utf8.DecodeRuneInString(word)
+		b.WriteRune(unicode.ToUpper(r))
+		b.WriteString(word[n:])
 	}
-	return nil
+	id := b.String()
+	if r, _ := utf8.DecodeRuneInString(id); !unicode.IsUpper(r) {
+		return ""
+	}
+	return id
 }
 
 // section is a named group of flags, used by the documentation generators.
@@ -347,6 +1495,15 @@ type section struct {
 	Flags []Flag
 }
 
+// docSection is a section as seen by the HTML template, together with the
+// settings for its table.
+type docSection struct {
+	section
+	Columns      []string
+	MarkdownHelp bool
+	Anchors      bool
+}
+
 // groupBySection groups flags by their section key, ordering the sections by
 // first appearance in the configuration file. If no flag declares a section a
 // single anonymous section holding every flag is returned, so that output for
@@ -388,6 +1545,219 @@ func groupBySection(flags []Flag) ([]section, error) {
 	return sections, nil
 }
 
+// flagDefault returns the default value of the given flag as the generated Go
+// code sees it. An unset default is the zero value of the flag's type, and the
+// default of a []string flag is split on the flag's delimiter.
+func flagDefault(flag Flag) (interface{}, error) {
+	switch flag.Type {
+	case "string", "filepath":
+		if flag.Default == nil {
+			return "", nil
+		}
+		return fmt.Sprint(flag.Default), nil
+	case "time.Time":
+		layout := flag.Layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		var s string
+		switch d := flag.Default.(type) {
+		case nil:
+			return "", nil
+		case time.Time:
+			// TOML and YAML have timestamps of their own.
+			s = d.Format(layout)
+		case string:
+			s = d
+		case fmt.Stringer:
+			// TOML's local dates and times, taken as written.
+			s = d.String()
+		default:
+			return nil, fmt.Errorf("time.Time flag %s has non-time default", flag.Name)
+		}
+		if s == "" {
+			return s, nil
+		}
+		if _, err := time.Parse(layout, s); err != nil {
+			return nil, fmt.Errorf("time.Time flag %s has invalid default: %v", flag.Name, err)
+		}
+		return s, nil
+	case "regexp":
+		if flag.Default == nil {
+			return "", nil
+		}
+		s, ok := flag.Default.(string)
+		if !ok {
+			return nil, fmt.Errorf("regexp flag %s has non-string default", flag.Name)
+		}
+		if _, err := regexp.Compile(s); err != nil {
+			return nil, fmt.Errorf("regexp flag %s has invalid default: %v", flag.Name, err)
+		}
+		return s, nil
+	case "custom":
+		if flag.Default == nil {
+			return "", nil
+		}
+		s, ok := flag.Default.(string)
+		if !ok {
+			return nil, fmt.Errorf("custom flag %s has non-string default", flag.Name)
+		}
+		return s, nil
+	case "hostport", "ip", "cidr", "url":
+		if flag.Default == nil {
+			return "", nil
+		}
+		s, ok := flag.Default.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s flag %s has non-string default", flag.Type, flag.Name)
+		}
+		if s == "" {
+			return s, nil
+		}
+		if err := checkAddress(flag.Type, s); err != nil {
+			return nil, fmt.Errorf("%s flag %s has invalid default: %v", flag.Type, flag.Name, err)
+		}
+		return s, nil
+	case "bool":
+		if flag.Default == nil {
+			return false, nil
+		}
+		b, ok := flag.Default.(bool)
+		if !ok {
+			return nil, fmt.Errorf("bool flag %s has non-bool default", flag.Name)
+		}
+		return b, nil
+	case "int", "int32", "int64", "uint", "uint16", "uint32", "uint64", "counter":
+		var i int64
+		switch d := flag.Default.(type) {
+		case nil:
+		case int:
+			i = int64(d)
+		case int64:
+			i = d
+		case float64:
+			// JSON has only one type of number, and so gives integers as
+			// floats.
+			if d != math.Trunc(d) || d < math.MinInt64 || d >= math.MaxInt64 {
+				return nil, fmt.Errorf("%s flag %s has non-integer default", flag.Type, flag.Name)
+			}
+			i = int64(d)
+		default:
+			return nil, fmt.Errorf("%s flag %s has non-integer default", flag.Type, flag.Name)
+		}
+		if r := intRanges[flag.Type]; i < r[0] || i > r[1] {
+			return nil, fmt.Errorf("%s flag %s has default %d, out of range", flag.Type, flag.Name, i)
+		}
+		if flag.Max > 0 && i > int64(flag.Max) {
+			return nil, fmt.Errorf("counter flag %s has default %d, more than its max %d", flag.Name, i, flag.Max)
+		}
+		return i, nil
+	case "float64":
+		var f float64
+		switch d := flag.Default.(type) {
+		case nil:
+		case float64:
+			f = d
+		case int:
+			f = float64(d)
+		case int64:
+			f = float64(d)
+		default:
+			return nil, fmt.Errorf("float64 flag %s has non-numeric default", flag.Name)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("float64 flag %s has non-finite default", flag.Name)
+		}
+		return f, nil
+	case "time.Duration":
+		if flag.Default == nil {
+			return "0s", nil
+		}
+		s, ok := flag.Default.(string)
+		if !ok {
+			return nil, fmt.Errorf("time.Duration flag %s has non-string default", flag.Name)
+		}
+		if _, err := time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("time.Duration flag %s has invalid default: %v", flag.Name, err)
+		}
+		return s, nil
+	case "[]string":
+		s, _ := flag.Default.(string)
+		if s == "" {
+			return []string{}, nil
+		}
+		delim := flag.Delimiter
+		if delim == "" {
+			delim = ","
+		}
+		return strings.Split(s, delim), nil
+	default:
+		return nil, fmt.Errorf("flag %s has unsupported type %s", flag.Name, flag.Type)
+	}
+}
+
+// intRanges holds the smallest and largest defaults of each integer type.
+// Defaults are read as int64, so none may exceed math.MaxInt64.
+var intRanges = map[string][2]int64{
+	"int":     {math.MinInt64, math.MaxInt64},
+	"int32":   {math.MinInt32, math.MaxInt32},
+	"int64":   {math.MinInt64, math.MaxInt64},
+	"uint":    {0, math.MaxInt64},
+	"uint16":  {0, math.MaxUint16},
+	"uint32":  {0, math.MaxUint32},
+	"uint64":  {0, math.MaxInt64},
+	"counter": {0, math.MaxInt},
+}
+
+// checkAddress returns an error if s is not a valid value of the given network
+// address type, as the generated code would parse it.
+func checkAddress(typ, s string) error {
+	var err error
+	switch typ {
+	case "hostport":
+		_, _, err = net.SplitHostPort(s)
+	case "ip":
+		_, err = netip.ParseAddr(s)
+	case "cidr":
+		_, err = netip.ParsePrefix(s)
+	case "url":
+		_, err = url.Parse(s)
+	}
+	return err
+}
+
+// flagExamples returns the example values of the given flag as they would be
+// given on the command line, such as -http-addr=0.0.0.0:4001.
+func flagExamples(flag Flag) []string {
+	var examples []string
+	for _, value := range flag.Examples {
+		examples = append(examples, fmt.Sprintf("-%s=%s", strings.TrimLeft(flag.CLI, "-"), shellQuote(value)))
+	}
+	return examples
+}
+
+// shellQuote quotes s, if necessary, so that a POSIX shell treats it as a
+// single word.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.,:/@%+=", r)
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// renderMarkdown renders CommonMark as HTML. Raw HTML in the input is omitted,
+// and links with dangerous schemes such as javascript: are dropped, so the
+// output is safe to embed in a page.
+func renderMarkdown(text string) (string, error) {
+	var buf bytes.Buffer
+	if err := goldmark.Convert([]byte(text), &buf); err != nil {
+		return "", fmt.Errorf("failed to render Markdown: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
 // escapeMarkdown escapes markdown special characters.
 func escapeMarkdown(text string) string {
 	text = strings.ReplaceAll(text, "|", "\\|")
@@ -3,7 +3,15 @@ package flagforge
 import (
 	"bytes"
 	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"math"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -173,16 +181,202 @@ func Test_Generator_HTMLGoldenFiles(t *testing.T) {
 	}
 }
 
-// Test_Generator_SectionsIgnoredByGo checks that adding sections to a
-// configuration file has no effect on the generated Go code.
-func Test_Generator_SectionsIgnoredByGo(t *testing.T) {
-	withSections := `
+// Test_Generator_ForgeWithOptions checks that the generated code offers a
+// variant of Forge which writes to a caller-supplied io.Writer and returns,
+// rather than exits on, parse errors.
+func Test_Generator_ForgeWithOptions(t *testing.T) {
+	toml := `
+	[go]
+	flag_set_usage = 'Usage: test [flags]\n'
+
+	[[flags]]
+	name = "HTTPAddr"
+	cli = "http-addr"
+	type = "string"
+	default = "localhost:4001"
+	short_help = "HTTP server bind address"
+	`
+	src := mustGenerate(t, toml, Go)
+	mustTypeCheck(t, src)
+
+	for _, s := range []string{
+		"func ForgeWithOptions(arguments []string, opts ...ForgeOption) (*flag.FlagSet, *Config, error)",
+		"return forge(arguments, o.output, flag.ContinueOnError)",
+		"return forge(arguments, os.Stderr, flag.ExitOnError)",
+		"fs.SetOutput(output)",
+		`usage(fs.Output(), "Usage: test [flags]\n")`,
+	} {
+		if !bytes.Contains(src, []byte(s)) {
+			t.Errorf("generated code does not contain %q", s)
+		}
+	}
+}
+
+func Test_Generator_ExamplesAndSince(t *testing.T) {
+	toml := `
+	[[flags]]
+	name = "HTTPAddr"
+	cli = "http-addr"
+	type = "string"
+	default = "localhost:4001"
+	short_help = "HTTP server bind address"
+	examples = ["0.0.0.0:4001", "my host:4001"]
+	since = "8.20.0"
+
 	[[flags]]
 	name = "NodeID"
 	cli = "node-id"
 	type = "string"
-	default = ""
 	short_help = "Node ID"
+	`
+
+	html := mustGenerate(t, toml, HTML)
+	for _, s := range []string{
+		`<td><code>-http-addr</code><br><span class="rq-since">Since 8.20.0</span></td>`,
+		`<br><br>Examples: <code>-http-addr=0.0.0.0:4001</code>, <code>-http-addr=&#39;my host:4001&#39;</code></td>`,
+		`<td><code>-node-id</code></td>`,
+	} {
+		if !bytes.Contains(html, []byte(s)) {
+			t.Errorf("HTML does not contain %q:\n%s", s, html)
+		}
+	}
+
+	md := mustGenerate(t, toml, Markdown)
+	for _, s := range []string{
+		"|http-addr<br>*Since 8.20.0*|",
+		"<br><br>Examples: `-http-addr=0.0.0.0:4001`, `-http-addr='my host:4001'`|",
+		"|node-id|Node ID|",
+	} {
+		if !bytes.Contains(md, []byte(s)) {
+			t.Errorf("Markdown does not contain %q:\n%s", s, md)
+		}
+	}
+}
+
+func Test_Generator_DocColumns(t *testing.T) {
+	flags := `
+	[env]
+	prefix = "RQLITE_"
+
+	[[flags]]
+	name = "JoinInterval"
+	cli = "join-interval"
+	type = "time.Duration"
+	default = "3s"
+	short_help = "Time between join attempts"
+	long_help = "Only used when joining."
+	section = "Clustering"
+
+	[[flags]]
+	name = "JoinAs"
+	cli = "join-as"
+	type = "string"
+	short_help = "User to join as"
+	section = "Clustering"
+	`
+
+	t.Run("Default", func(t *testing.T) {
+		md := mustGenerate(t, flags, Markdown)
+		want := "| Flag | Usage |\n|-|-|\n" +
+			"|join-interval|Time between join attempts. Only used when joining.|\n" +
+			"|join-as|User to join as|\n"
+		if !bytes.Contains(md, []byte(want)) {
+			t.Fatalf("Markdown does not contain %q:\n%s", want, md)
+		}
+	})
+
+	t.Run("AllColumns", func(t *testing.T) {
+		toml := `
+		[docs]
+		columns = ["flag", "type", "default", "env", "section", "usage"]
+		` + flags
+
+		md := mustGenerate(t, toml, Markdown)
+		for _, s := range []string{
+			"| Flag | Type | Default | Environment variable | Section | Usage |\n|-|-|-|-|-|-|\n",
+			"|join-interval|`time.Duration`|`3s`|`RQLITE_JOIN_INTERVAL`|Clustering|Time between",
+			"|join-as|`string`|`\"\"`|`RQLITE_JOIN_AS`|Clustering|User to join as|",
+		} {
+			if !bytes.Contains(md, []byte(s)) {
+				t.Errorf("Markdown does not contain %q:\n%s", s, md)
+			}
+		}
+
+		html := mustGenerate(t, toml, HTML)
+		for _, s := range []string{
+			`<th class="col-cli">Flag</th>
+		<th class="col-type">Type</th>
+		<th class="col-default">Default</th>`,
+			`<td><code>time.Duration</code></td>
+		<td><code>3s</code></td>
+		<td><code>RQLITE_JOIN_INTERVAL</code></td>
+		<td>Clustering</td>`,
+		} {
+			if !bytes.Contains(html, []byte(s)) {
+				t.Errorf("HTML does not contain %q:\n%s", s, html)
+			}
+		}
+	})
+
+	t.Run("UnknownColumn", func(t *testing.T) {
+		tomlFile := mustWriteToTempTOMLFile("[docs]\ncolumns = [\"flag\", \"colour\"]\n" + flags)
+		defer os.Remove(tomlFile)
+		cfg, err := NewParser().ParsePath(tomlFile)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		gen, err := NewGenerator(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, f := range []Format{Markdown, HTML} {
+			if err := gen.Execute(f, new(bytes.Buffer)); err == nil {
+				t.Errorf("expected an error for an unknown column in %s", f)
+			}
+		}
+	})
+}
+
+func Test_Generator_MarkdownHelp(t *testing.T) {
+	flags := `
+	[[flags]]
+	name = "HTTPAddr"
+	cli = "http-addr"
+	type = "string"
+	short_help = "HTTP server bind address"
+	long_help = """
+Use ` + "`0.0.0.0:4001`" + ` to listen on all interfaces. See [the docs](https://rqlite.io).
+Beware <script>alert(1)</script> [Click](javascript:alert(1))
+"""
+	`
+
+	html := mustGenerate(t, flags, HTML)
+	if !bytes.Contains(html, []byte("<br><br>Use `0.0.0.0:4001` to listen")) {
+		t.Errorf("long help treated as Markdown by default:\n%s", html)
+	}
+
+	html = mustGenerate(t, "[docs]\nmarkdown_help = true\n"+flags, HTML)
+	for _, s := range []string{
+		`<p>Use <code>0.0.0.0:4001</code> to listen on all interfaces. See <a href="https://rqlite.io">the docs</a>.`,
+		`<a href="">Click</a></p></td>`,
+	} {
+		if !bytes.Contains(html, []byte(s)) {
+			t.Errorf("HTML does not contain %q:\n%s", s, html)
+		}
+	}
+	if bytes.Contains(html, []byte("<script>")) {
+		t.Errorf("raw HTML in long help was not omitted:\n%s", html)
+	}
+}
+
+func Test_Generator_Templates(t *testing.T) {
+	dir := t.TempDir()
+	toml := `
+	[[flags]]
+	name = "NodeID"
+	cli = "node-id"
+	type = "string"
+	short_help = "Unique ID for node"
 	section = "General"
 
 	[[flags]]
@@ -193,16 +387,61 @@ func Test_Generator_SectionsIgnoredByGo(t *testing.T) {
 	short_help = "HTTP server bind address"
 	section = "HTTP API"
 	`
-	withoutSections := strings.ReplaceAll(
-		strings.ReplaceAll(withSections, "\tsection = \"General\"\n", ""),
-		"\tsection = \"HTTP API\"\n", "")
+	generate := func(t *testing.T, toml string, f Format) string {
+		t.Helper()
+		tomlFile := filepath.Join(dir, "in.toml")
+		if err := os.WriteFile(tomlFile, []byte(toml), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := NewParser().ParsePath(tomlFile)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		gen, err := NewGenerator(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		buf := new(bytes.Buffer)
+		if err := gen.Execute(f, buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return buf.String()
+	}
 
-	generate := func(toml string) []byte {
-		tomlFile := mustWriteToTempTOMLFile(toml)
-		defer os.Remove(tomlFile)
+	t.Run("HTML", func(t *testing.T) {
+		tmpl := `<h3>{{ .Name }}</h3>
+{{ range .Flags }}<dt>{{ .CLI }}</dt><dd>{{ .ShortHelp | html }} ({{ cell . "default" }})</dd>
+{{ end }}`
+		if err := os.WriteFile(filepath.Join(dir, "section.html.tmpl"), []byte(tmpl), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got := generate(t, "[templates]\nhtml = \"section.html.tmpl\"\n"+toml, HTML)
+		want := `<h3>General</h3>
+<dt>node-id</dt><dd>Unique ID for node ("")</dd>
 
-		parser := NewParser()
-		cfg, err := parser.ParsePath(tomlFile)
+<h3>HTTP API</h3>
+<dt>http-addr</dt><dd>HTTP server bind address (localhost:4001)</dd>
+`
+		if got != want {
+			t.Fatalf("HTML from template is:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("RedefineTable", func(t *testing.T) {
+		tmpl := `{{ define "table" }}<ul>{{ range .Flags }}<li>{{ .CLI }}</li>{{ end }}</ul>
+{{ end }}{{ template "table" . }}`
+		if err := os.WriteFile(filepath.Join(dir, "table.tmpl"), []byte(tmpl), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got := generate(t, "[templates]\nhtml = \"table.tmpl\"\n"+toml, HTML)
+		want := "<ul><li>node-id</li></ul>\n\n<ul><li>http-addr</li></ul>\n"
+		if got != want {
+			t.Fatalf("HTML from template is:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		cfg, err := NewParser().ParsePath(mustWriteToTempTOMLFile(toml))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -210,15 +449,1243 @@ func Test_Generator_SectionsIgnoredByGo(t *testing.T) {
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
+		if err := gen.SetTemplate(Markdown, "### {{ .Name }}\n{{ range .Flags }}- `{{ escapeMarkdown .CLI }}`\n{{ end }}"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
 		buf := new(bytes.Buffer)
-		if err := gen.Execute(Go, buf); err != nil {
+		if err := gen.Execute(Markdown, buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := "### General\n- `node-id`\n\n### HTTP API\n- `http-addr`\n"; buf.String() != want {
+			t.Fatalf("Markdown from template is:\n%s\nwant:\n%s", buf, want)
+		}
+	})
+
+	t.Run("Go", func(t *testing.T) {
+		tmpl := `package {{ .Pkg }}
+
+// Sections lists the flags in each section.
+var Sections = map[string][]string{
+{{- range .Sections }}
+	{{ printf "%q" .Name }}: { {{- range .Flags }}{{ printf "%q" .CLI }}, {{ end }}},
+{{- end }}
+}
+`
+		if err := os.WriteFile(filepath.Join(dir, "go.tmpl"), []byte(tmpl), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got := generate(t, "[templates]\ngo = \"go.tmpl\"\n"+toml, Go)
+		mustTypeCheck(t, []byte(got))
+		if !strings.Contains(got, `"HTTP API": {"http-addr"},`) {
+			t.Fatalf("Go from template is not as expected:\n%s", got)
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		gen, err := NewGenerator(&ParsedConfig{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := gen.SetTemplate(JSONSchema, "{}"); err == nil {
+			t.Fatal("expected error setting template for JSON Schema")
+		}
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		cfg, err := NewParser().ParsePath(mustWriteToTempTOMLFile("[templates]\nhtml = \"missing.tmpl\"\n" + toml))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := NewGenerator(cfg); err == nil {
+			t.Fatal("expected error for missing template file")
+		}
+	})
+}
+
+// forgeRun is a command line given to a program built by mustBuildForge, and
+// what the program should print. $DIR in either stands for the directory the
+// program runs in, which is also its home directory.
+type forgeRun struct {
+	args []string
+	want []string
+}
+
+// Test_Generator_RunForge builds programs from generated code, and checks what
+// ForgeWithOptions makes of command lines: the values parsed, the checks made
+// as they are, and the changes made to them afterwards.
+func Test_Generator_RunForge(t *testing.T) {
+	for name, tt := range map[string]struct {
+		toml string
+
+		// files are created in each run's directory before it runs.
+		files map[string]string
+
+		runs map[string]forgeRun
+	}{
+		"FilepathOptions": {
+			toml: `
+			[[flags]]
+			name = "Cert"
+			cli = "cert"
+			type = "filepath"
+			short_help = "Path to TLS certificate"
+			must_exist = true
+			kind = "file"
+
+			[[flags]]
+			name = "DataDir"
+			cli = "data-dir"
+			type = "filepath"
+			default = "~/data"
+			short_help = "Path to data directory"
+			expand_home = true
+			make_absolute = true
+			create_dir = true
+			must_exist = true
+			kind = "dir"
+
+			[[flags]]
+			name = "Log"
+			cli = "log"
+			type = "filepath"
+			short_help = "Path to log file"
+			make_absolute = true
+			create_dir = true
+			kind = "file"
+
+			[[flags]]
+			name = "Backups"
+			cli = "backups"
+			type = "filepath"
+			short_help = "Path to backup directory"
+			kind = "dir"
+			`,
+			files: map[string]string{"cert.pem": "cert", "file": "file"},
+			runs: map[string]forgeRun{
+				"Defaults": {
+					want: []string{"{Cert: DataDir:$DIR/data Log: Backups:}"},
+				},
+				"Set": {
+					args: []string{"-cert", "cert.pem", "-data-dir", "~/a/b", "-log", "logs/app.log", "-backups", "missing"},
+					want: []string{"{Cert:cert.pem DataDir:$DIR/a/b Log:$DIR/logs/app.log Backups:missing}"},
+				},
+				"Missing": {
+					args: []string{"-cert", "missing.pem"},
+					want: []string{"error: flag -cert: missing.pem does not exist"},
+				},
+				"NotAFile": {
+					args: []string{"-cert", "$DIR"},
+					want: []string{"error: flag -cert: $DIR is not a file"},
+				},
+				"NotADirectory": {
+					args: []string{"-backups", "file"},
+					want: []string{"error: flag -backups: file is not a directory"},
+				},
+				"CannotCreate": {
+					args: []string{"-data-dir", "file/data"},
+					want: []string{"error: flag -data-dir: failed to create directory: "},
+				},
+			},
+		},
+		"NetworkTypes": {
+			toml: `
+			[[flags]]
+			name = "HTTPAddr"
+			cli = "http-addr"
+			type = "hostport"
+			default = "localhost:4001"
+			short_help = "HTTP server bind address"
+
+			[[flags]]
+			name = "BindIP"
+			cli = "bind-ip"
+			type = "ip"
+			default = "127.0.0.1"
+			short_help = "IP address to bind to"
+
+			[[flags]]
+			name = "Allow"
+			cli = "allow"
+			type = "cidr"
+			short_help = "Network allowed to connect"
+
+			[[flags]]
+			name = "JoinURL"
+			cli = "join-url"
+			type = "url"
+			short_help = "URL of a node to join"
+			`,
+			runs: map[string]forgeRun{
+				"Defaults": {
+					want: []string{"{HTTPAddr:localhost:4001 BindIP:127.0.0.1 Allow:invalid Prefix JoinURL:<nil>}"},
+				},
+				"Set": {
+					args: []string{"-http-addr", "[::1]:80", "-bind-ip", "::1", "-allow", "10.0.0.0/8", "-join-url", "http://node1:4001/join"},
+					want: []string{"{HTTPAddr:[::1]:80 BindIP:::1 Allow:10.0.0.0/8 JoinURL:http://node1:4001/join}"},
+				},
+				"BadHostPort": {
+					args: []string{"-http-addr", "localhost"},
+					want: []string{"error: ", "missing port in address"},
+				},
+				"BadIP": {
+					args: []string{"-bind-ip", "300.0.0.1"},
+					want: []string{"error: ", "IPv4 field has value >255"},
+				},
+				"BadCIDR": {
+					args: []string{"-allow", "10.0.0.0"},
+					want: []string{"error: ", "no '/'"},
+				},
+				"BadURL": {
+					args: []string{"-join-url", "http://[::1"},
+					want: []string{"error: ", "missing ']' in host"},
+				},
+			},
+		},
+		"DefaultFrom": {
+			toml: `
+			[[flags]]
+			name = "NodeID"
+			cli = "node-id"
+			type = "string"
+			default_from = "raft-adv-addr"
+			short_help = "Unique ID for node"
+
+			[[flags]]
+			name = "RaftAddr"
+			cli = "raft-addr"
+			type = "string"
+			default = "localhost:4002"
+			short_help = "Raft communication bind address"
+
+			[[flags]]
+			name = "RaftAdvAddr"
+			cli = "raft-adv-addr"
+			type = "string"
+			default_from = "-raft-addr"
+			short_help = "Advertised Raft communication address"
+
+			[[flags]]
+			name = "Port"
+			cli = "port"
+			type = "int"
+			default = 4001
+			short_help = "Port to listen on"
+
+			[[flags]]
+			name = "AdvPort"
+			cli = "adv-port"
+			type = "int"
+			default_from = "port"
+			short_help = "Advertised port"
+			`,
+			runs: map[string]forgeRun{
+				"Defaults": {
+					want: []string{"{NodeID:localhost:4002 RaftAddr:localhost:4002 RaftAdvAddr:localhost:4002 Port:4001 AdvPort:4001}"},
+				},
+				"Chained": {
+					args: []string{"-raft-addr", "node1:4002", "-port", "80"},
+					want: []string{"{NodeID:node1:4002 RaftAddr:node1:4002 RaftAdvAddr:node1:4002 Port:80 AdvPort:80}"},
+				},
+				"SetInChain": {
+					args: []string{"-raft-adv-addr", "node1:4002"},
+					want: []string{"{NodeID:node1:4002 RaftAddr:localhost:4002 RaftAdvAddr:node1:4002 Port:4001 AdvPort:4001}"},
+				},
+				"SetEmpty": {
+					args: []string{"-node-id", "", "-adv-port", "0"},
+					want: []string{"{NodeID:localhost:4002 RaftAddr:localhost:4002 RaftAdvAddr:localhost:4002 Port:4001 AdvPort:0}"},
+				},
+			},
+		},
+		"NumericTypes": {
+			toml: `
+			[[flags]]
+			name = "Ratio"
+			cli = "ratio"
+			type = "float64"
+			default = 0.75
+			short_help = "Fraction of nodes which must respond"
+
+			[[flags]]
+			name = "Workers"
+			cli = "workers"
+			type = "uint"
+			default = 4
+			short_help = "Number of workers"
+
+			[[flags]]
+			name = "Offset"
+			cli = "offset"
+			type = "int32"
+			default = -5
+			short_help = "Clock offset"
+
+			[[flags]]
+			name = "MaxSize"
+			cli = "max-size"
+			type = "uint32"
+			short_help = "Maximum message size"
+
+			[[flags]]
+			name = "Port"
+			cli = "port"
+			type = "uint16"
+			default = 4001
+			short_help = "Port to listen on"
+			`,
+			runs: map[string]forgeRun{
+				"Defaults": {
+					want: []string{"{Ratio:0.75 Workers:4 Offset:-5 MaxSize:0 Port:4001}"},
+				},
+				"Set": {
+					args: []string{"-ratio", "1e-1", "-workers", "8", "-offset", "-2147483648", "-max-size", "4294967295", "-port", "65535"},
+					want: []string{"{Ratio:0.1 Workers:8 Offset:-2147483648 MaxSize:4294967295 Port:65535}"},
+				},
+				"PortOutOfRange": {
+					args: []string{"-port", "65536"},
+					want: []string{"error: ", "value out of range"},
+				},
+				"NegativeUint": {
+					args: []string{"-max-size", "-1"},
+					want: []string{`error: invalid value "-1" for flag -max-size: value out of range`},
+				},
+				"OffsetOutOfRange": {
+					args: []string{"-offset", "2147483648"},
+					want: []string{"error: ", "value out of range"},
+				},
+			},
+		},
+		"CustomTypes": {
+			toml: `
+			[[flags]]
+			name = "LogLevel"
+			cli = "log-level"
+			type = "custom"
+			go_type = "slog.Level"
+			import = "log/slog"
+			default = "WARN"
+			short_help = "Minimum level of logged messages"
+
+			[[flags]]
+			name = "Since"
+			cli = "since"
+			type = "custom"
+			go_type = "time.Time"
+			import = "time"
+			short_help = "Ignore changes before this time"
+			`,
+			runs: map[string]forgeRun{
+				"Defaults": {
+					want: []string{"{LogLevel:WARN Since:0001-01-01 00:00:00 +0000 UTC}"},
+				},
+				"Set": {
+					args: []string{"-log-level", "debug+2", "-since", "2024-05-01T12:00:00Z"},
+					want: []string{"{LogLevel:DEBUG+2 Since:2024-05-01 12:00:00 +0000 UTC}"},
+				},
+				"Invalid": {
+					args: []string{"-log-level", "LOUD"},
+					want: []string{"error: ", `slog: level string "LOUD": unknown name`},
+				},
+			},
+		},
+		"TimeAndRegexp": {
+			toml: `
+			[[flags]]
+			name = "RestoreAt"
+			cli = "restore-at"
+			type = "time.Time"
+			default = 2024-03-01T10:00:00Z
+			short_help = "Point in time to restore to"
+
+			[[flags]]
+			name = "Day"
+			cli = "day"
+			type = "time.Time"
+			layout = "2006-01-02"
+			default = 2024-03-01
+			short_help = "Day of the report"
+
+			[[flags]]
+			name = "IDPattern"
+			cli = "id-pattern"
+			type = "regexp"
+			default = '^"?\d+"?$'
+			short_help = "Pattern of node IDs"
+
+			[[flags]]
+			name = "LogFilter"
+			cli = "log-filter"
+			type = "regexp"
+			short_help = "Only log messages matching this expression"
+			`,
+			runs: map[string]forgeRun{
+				"Defaults": {
+					want: []string{`{RestoreAt:2024-03-01 10:00:00 +0000 UTC Day:2024-03-01 00:00:00 +0000 UTC IDPattern:^"?\d+"?$ LogFilter:<nil>}`},
+				},
+				"Set": {
+					args: []string{"-restore-at", "2024-05-01T12:00:00+02:00", "-day", "2024-05-02", "-log-filter", `^raft\b`},
+					want: []string{`{RestoreAt:2024-05-01 12:00:00 +0200 +0200 Day:2024-05-02 00:00:00 +0000 UTC IDPattern:^"?\d+"?$ LogFilter:^raft\b}`},
+				},
+				"TimeNotInLayout": {
+					args: []string{"-day", "2024-05-02T12:00:00Z"},
+					want: []string{"error: ", `parsing time "2024-05-02T12:00:00Z": extra text: "T12:00:00Z"`},
+				},
+				"InvalidRegexp": {
+					args: []string{"-log-filter", "("},
+					want: []string{"error: ", "error parsing regexp: missing closing ): `(`"},
+				},
+			},
+		},
+		"Counter": {
+			toml: `
+			[[flags]]
+			name = "Verbosity"
+			cli = "v"
+			type = "counter"
+			max = 3
+			short_help = "Increase verbosity"
+
+			[[flags]]
+			name = "Quiet"
+			cli = "q"
+			type = "counter"
+			default = 1
+			short_help = "Decrease verbosity"
+			`,
+			runs: map[string]forgeRun{
+				"Defaults": {
+					want: []string{"{Verbosity:0 Quiet:1}"},
+				},
+				"Repeated": {
+					args: []string{"-v", "-v", "-q"},
+					want: []string{"{Verbosity:2 Quiet:2}"},
+				},
+				"UpToMax": {
+					args: []string{"-v", "-v", "-v", "-v"},
+					want: []string{"{Verbosity:3 Quiet:1}"},
+				},
+				"Set": {
+					args: []string{"-v=2", "-v", "-q=0"},
+					want: []string{"{Verbosity:3 Quiet:0}"},
+				},
+				"SetAboveMax": {
+					args: []string{"-v=9"},
+					want: []string{"{Verbosity:3 Quiet:1}"},
+				},
+				"SetNegative": {
+					args: []string{"-q=-1"},
+					want: []string{`error: invalid boolean value "-1" for -q: value out of range`},
+				},
+			},
+		},
+		"QuotedDefaults": {
+			toml: `
+			[[flags]]
+			name = "Greeting"
+			cli = "greeting"
+			type = "string"
+			default = 'say "hi" \o/'
+			short_help = "Greeting to send"
+
+			[[flags]]
+			name = "Share"
+			cli = "share"
+			type = "filepath"
+			default = '\\server\"share"'
+			short_help = "Share to mount"
+
+			[[flags]]
+			name = "Tags"
+			cli = "tags"
+			type = "[]string"
+			default = 'a"b,c\d'
+			short_help = "Tags to add"
+
+			[[flags]]
+			name = "LogLevel"
+			cli = "log-level"
+			type = "custom"
+			go_type = "slog.Level"
+			import = "log/slog"
+			default = "INFO+1"
+			short_help = "Minimum level of logged messages"
+			`,
+			runs: map[string]forgeRun{
+				"Defaults": {
+					want: []string{`{Greeting:say "hi" \o/ Share:\\server\"share" Tags:[a"b c\d] LogLevel:INFO+1}`},
+				},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bin := mustBuildForge(t, tt.toml)
+			for name, run := range tt.runs {
+				t.Run(name, func(t *testing.T) {
+					dir, err := filepath.EvalSymlinks(t.TempDir())
+					if err != nil {
+						t.Fatal(err)
+					}
+					mustWriteFiles(t, dir, tt.files)
+					var args []string
+					for _, arg := range run.args {
+						args = append(args, strings.ReplaceAll(arg, "$DIR", dir))
+					}
+					cmd := exec.Command(bin, args...)
+					cmd.Dir = dir
+					cmd.Env = append(os.Environ(), "HOME="+dir)
+					out, err := cmd.CombinedOutput()
+					if err != nil {
+						t.Fatalf("failed to run generated code: %v\n%s", err, out)
+					}
+					for _, s := range run.want {
+						s = strings.ReplaceAll(s, "$DIR", dir)
+						if !strings.Contains(string(out), s) {
+							t.Errorf("output does not contain %q:\n%s", s, out)
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+// Test_NewGenerator_Errors checks that flags which the generated code could not
+// handle are rejected before anything is generated.
+func Test_NewGenerator_Errors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		args  []Argument
+		flags []Flag
+		err   string
+	}{
+		"ArgumentNotString": {
+			args: []Argument{{Name: "Port", Type: "int"}},
+			err:  `argument Port has type "int", must be string`,
+		},
+		"ArgumentWithoutType": {
+			args: []Argument{{Name: "DataDir"}},
+			err:  `argument DataDir has type "", must be string`,
+		},
+		"OptionsNotFilepath": {
+			flags: []Flag{{Name: "Addr", CLI: "addr", Type: "string", MustExist: true}},
+			err:   "flag Addr sets filesystem options but is of type string, not filepath",
+		},
+		"UnknownKind": {
+			flags: []Flag{{Name: "Cert", CLI: "cert", Type: "filepath", Kind: "socket"}},
+			err:   `flag Cert has kind "socket", must be file or dir`,
+		},
+		"InvalidHostPort": {
+			flags: []Flag{{Name: "Addr", CLI: "addr", Type: "hostport", Default: "localhost"}},
+			err:   "hostport flag Addr has invalid default: address localhost: missing port in address",
+		},
+		"InvalidIP": {
+			flags: []Flag{{Name: "BindIP", CLI: "bind-ip", Type: "ip", Default: "300.0.0.1"}},
+			err:   `ip flag BindIP has invalid default: ParseAddr("300.0.0.1"): IPv4 field has value >255`,
+		},
+		"InvalidCIDR": {
+			flags: []Flag{{Name: "Allow", CLI: "allow", Type: "cidr", Default: "10.0.0.0"}},
+			err:   `cidr flag Allow has invalid default: netip.ParsePrefix("10.0.0.0"): no '/'`,
+		},
+		"InvalidURL": {
+			flags: []Flag{{Name: "JoinURL", CLI: "join-url", Type: "url", Default: "http://[::1"}},
+			err:   `url flag JoinURL has invalid default: parse "http://[::1": missing ']' in host`,
+		},
+		"DefaultFromUnknownFlag": {
+			flags: []Flag{
+				{Name: "AdvAddr", CLI: "adv-addr", Type: "string", DefaultFrom: "addr"},
+			},
+			err: "flag AdvAddr takes its default from unknown flag -addr",
+		},
+		"DefaultFromTypeMismatch": {
+			flags: []Flag{
+				{Name: "Port", CLI: "port", Type: "int"},
+				{Name: "AdvAddr", CLI: "adv-addr", Type: "string", DefaultFrom: "port"},
+			},
+			err: "flag AdvAddr of type string takes its default from flag Port of type int",
+		},
+		"DefaultAndDefaultFrom": {
+			flags: []Flag{
+				{Name: "Addr", CLI: "addr", Type: "string"},
+				{Name: "AdvAddr", CLI: "adv-addr", Type: "string", Default: "x", DefaultFrom: "addr"},
+			},
+			err: "flag AdvAddr sets both default and default_from",
+		},
+		"DefaultFromCycle": {
+			flags: []Flag{
+				{Name: "A", CLI: "a", Type: "string", DefaultFrom: "b"},
+				{Name: "B", CLI: "b", Type: "string", DefaultFrom: "a"},
+			},
+			err: "default_from cycle: -a -> -b -> -a",
+		},
+		"UnsupportedType": {
+			flags: []Flag{{Name: "Count", CLI: "count", Type: "int8"}},
+			err:   "flag Count has unsupported type int8",
+		},
+		"PortOutOfRange": {
+			flags: []Flag{{Name: "Port", CLI: "port", Type: "uint16", Default: int64(70000)}},
+			err:   "uint16 flag Port has default 70000, out of range",
+		},
+		"NegativeUint": {
+			flags: []Flag{{Name: "Workers", CLI: "workers", Type: "uint", Default: int64(-1)}},
+			err:   "uint flag Workers has default -1, out of range",
+		},
+		"FractionalInt": {
+			flags: []Flag{{Name: "Workers", CLI: "workers", Type: "int", Default: 2.5}},
+			err:   "int flag Workers has non-integer default",
+		},
+		"StringFloat": {
+			flags: []Flag{{Name: "Ratio", CLI: "ratio", Type: "float64", Default: "0.5"}},
+			err:   "float64 flag Ratio has non-numeric default",
+		},
+		"InfiniteFloat": {
+			flags: []Flag{{Name: "Ratio", CLI: "ratio", Type: "float64", Default: math.Inf(1)}},
+			err:   "float64 flag Ratio has non-finite default",
+		},
+		"NoGoType": {
+			flags: []Flag{{Name: "LogLevel", CLI: "log-level", Type: "custom"}},
+			err:   "custom flag LogLevel has no go_type",
+		},
+		"PointerGoType": {
+			flags: []Flag{{Name: "LogLevel", CLI: "log-level", Type: "custom", GoType: "*slog.Level"}},
+			err:   `custom flag LogLevel has go_type "*slog.Level", must be a named type, not a pointer`,
+		},
+		"InvalidGoType": {
+			flags: []Flag{{Name: "LogLevel", CLI: "log-level", Type: "custom", GoType: "slog."}},
+			err:   `custom flag LogLevel has invalid go_type "slog."`,
+		},
+		"GoTypeNotCustom": {
+			flags: []Flag{{Name: "LogLevel", CLI: "log-level", Type: "string", GoType: "slog.Level"}},
+			err:   "flag LogLevel sets go_type, import or type_doc but is of type string, not custom",
+		},
+		"DefaultFromOtherGoType": {
+			flags: []Flag{
+				{Name: "Level", CLI: "level", Type: "custom", GoType: "slog.Level"},
+				{Name: "Since", CLI: "since", Type: "custom", GoType: "time.Time", DefaultFrom: "level"},
+			},
+			err: "flag Since of type time.Time takes its default from flag Level of type slog.Level",
+		},
+		"InvalidTime": {
+			flags: []Flag{{Name: "Day", CLI: "day", Type: "time.Time", Layout: "2006-01-02", Default: "2024-02-30"}},
+			err:   `time.Time flag Day has invalid default: parsing time "2024-02-30": day out of range`,
+		},
+		"TimeNotInLayout": {
+			flags: []Flag{{Name: "Day", CLI: "day", Type: "time.Time", Default: "2024-03-01"}},
+			err:   `time.Time flag Day has invalid default: parsing time "2024-03-01" as "2006-01-02T15:04:05Z07:00"`,
+		},
+		"InvalidRegexp": {
+			flags: []Flag{{Name: "LogFilter", CLI: "log-filter", Type: "regexp", Default: "("}},
+			err:   "regexp flag LogFilter has invalid default: error parsing regexp: missing closing ): `(`",
+		},
+		"LayoutNotTime": {
+			flags: []Flag{{Name: "Day", CLI: "day", Type: "string", Layout: "2006-01-02"}},
+			err:   "flag Day sets layout but is of type string, not time.Time",
+		},
+		"NonStringRegexp": {
+			flags: []Flag{{Name: "LogFilter", CLI: "log-filter", Type: "regexp", Default: int64(1)}},
+			err:   "regexp flag LogFilter has non-string default",
+		},
+		"MaxNotCounter": {
+			flags: []Flag{{Name: "Level", CLI: "level", Type: "int", Max: 3}},
+			err:   "flag Level sets max but is of type int, not counter",
+		},
+		"NegativeMax": {
+			flags: []Flag{{Name: "Verbosity", CLI: "v", Type: "counter", Max: -1}},
+			err:   "counter flag Verbosity has negative max",
+		},
+		"CounterDefaultAboveMax": {
+			flags: []Flag{{Name: "Verbosity", CLI: "v", Type: "counter", Max: 3, Default: int64(4)}},
+			err:   "counter flag Verbosity has default 4, more than its max 3",
+		},
+		"NegativeCounterDefault": {
+			flags: []Flag{{Name: "Verbosity", CLI: "v", Type: "counter", Default: int64(-1)}},
+			err:   "counter flag Verbosity has default -1, out of range",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewGenerator(&ParsedConfig{Arguments: tt.args, Flags: tt.flags})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func Test_Generator_FilepathOptions(t *testing.T) {
+	toml := `
+	[[flags]]
+	name = "Cert"
+	cli = "cert"
+	type = "filepath"
+	short_help = "Path to TLS certificate"
+	must_exist = true
+	kind = "file"
+
+	[[flags]]
+	name = "DataDir"
+	cli = "data-dir"
+	type = "filepath"
+	default = "~/rqlite"
+	short_help = "Path to data directory"
+	expand_home = true
+	make_absolute = true
+	create_dir = true
+	kind = "dir"
+
+	`
+
+	// Without options, neither the check nor its import is generated.
+	plain := string(mustGenerate(t, `
+	[[flags]]
+	name = "Cert"
+	cli = "cert"
+	type = "filepath"
+	short_help = "Path to TLS certificate"
+	`, Go))
+	mustTypeCheck(t, []byte(plain))
+	if strings.Contains(plain, "checkPath") || strings.Contains(plain, `"path/filepath"`) {
+		t.Errorf("generated code checks paths without options:\n%s", plain)
+	}
+
+	md := string(mustGenerate(t, toml, Markdown))
+	html := string(mustGenerate(t, toml, HTML))
+	for _, s := range []string{
+		"Path to TLS certificate. Must be an existing, readable file.",
+		"A leading ~ is expanded to the home directory. A relative path is made absolute. Created if it doesn't exist. Must be a directory, if it exists.",
+	} {
+		if !strings.Contains(md, s) {
+			t.Errorf("Markdown does not contain %q:\n%s", s, md)
+		}
+	}
+	if !strings.Contains(html, "<br><br>Must be an existing, readable file.</td>") {
+		t.Errorf("HTML does not note requirements:\n%s", html)
+	}
+}
+
+func Test_PathRequirements(t *testing.T) {
+	for exp, flag := range map[string]Flag{
+		"":                            {},
+		"Must exist and be readable.": {MustExist: true},
+		"Must be an existing, readable directory.":                                    {MustExist: true, Kind: "dir"},
+		"Must be a file, if it exists.":                                               {Kind: "file"},
+		"Its directory is created if it doesn't exist. Must be a file, if it exists.": {CreateDir: true, Kind: "file"},
+	} {
+		if got := pathRequirements(flag); got != exp {
+			t.Errorf("pathRequirements(%+v) = %q, want %q", flag, got, exp)
+		}
+	}
+}
+
+func Test_Generator_NetworkTypes(t *testing.T) {
+	toml := `
+	[[flags]]
+	name = "HTTPAddr"
+	cli = "http-addr"
+	type = "hostport"
+	default = "localhost:4001"
+	short_help = "HTTP server bind address"
+
+	[[flags]]
+	name = "BindIP"
+	cli = "bind-ip"
+	type = "ip"
+	default = "127.0.0.1"
+	short_help = "IP address to bind to"
+
+	[[flags]]
+	name = "Allow"
+	cli = "allow"
+	type = "cidr"
+	short_help = "Network allowed to connect"
+
+	[[flags]]
+	name = "JoinURL"
+	cli = "join-url"
+	type = "url"
+	short_help = "URL of a node to join"
+	`
+
+	// Helpers, and their imports, are generated only for the types used.
+	hostport := string(mustGenerate(t, `
+	[[flags]]
+	name = "HTTPAddr"
+	cli = "http-addr"
+	type = "hostport"
+	short_help = "HTTP server bind address"
+	`, Go))
+	mustTypeCheck(t, []byte(hostport))
+	if strings.Contains(hostport, "mustUnmarshalText") || strings.Contains(hostport, "urlValue") {
+		t.Errorf("generated code has helpers for unused types:\n%s", hostport)
+	}
+
+	schema := string(mustGenerate(t, toml, JSONSchema))
+	if !strings.Contains(schema, `"format": "uri"`) {
+		t.Errorf("JSON Schema does not constrain URL:\n%s", schema)
+	}
+}
+
+func Test_Generator_NumericTypes(t *testing.T) {
+	toml := `
+	[[flags]]
+	name = "Ratio"
+	cli = "ratio"
+	type = "float64"
+	default = 0.75
+	short_help = "Fraction of nodes which must respond"
+
+	[[flags]]
+	name = "Workers"
+	cli = "workers"
+	type = "uint"
+	default = 4
+	short_help = "Number of workers"
+
+	[[flags]]
+	name = "Offset"
+	cli = "offset"
+	type = "int32"
+	default = -5
+	short_help = "Clock offset"
+
+	[[flags]]
+	name = "MaxSize"
+	cli = "max-size"
+	type = "uint32"
+	short_help = "Maximum message size"
+
+	[[flags]]
+	name = "Port"
+	cli = "port"
+	type = "uint16"
+	default = 4001
+	short_help = "Port to listen on"
+	`
+
+	schema := string(mustGenerate(t, toml, JSONSchema))
+	for _, s := range []string{`"type": "number"`, `"maximum": 65535`} {
+		if !strings.Contains(schema, s) {
+			t.Errorf("JSON Schema does not contain %q:\n%s", s, schema)
+		}
+	}
+}
+
+func Test_Generator_TimeAndRegexp(t *testing.T) {
+	toml := `
+	[[flags]]
+	name = "RestoreAt"
+	cli = "restore-at"
+	type = "time.Time"
+	default = 2024-03-01T10:00:00Z
+	short_help = "Point in time to restore to"
+
+	[[flags]]
+	name = "Day"
+	cli = "day"
+	type = "time.Time"
+	layout = "2006-01-02"
+	default = 2024-03-01
+	short_help = "Day of the report"
+
+	[[flags]]
+	name = "LogFilter"
+	cli = "log-filter"
+	type = "regexp"
+	default = "^raft"
+	short_help = "Only log messages matching this expression"
+
+	[[flags]]
+	name = "IDPattern"
+	cli = "id-pattern"
+	type = "regexp"
+	default = '^"?\d+"?$'
+	short_help = "Pattern of node IDs"
+	`
+
+	md := string(mustGenerate(t, toml, Markdown))
+	if !strings.Contains(md, "Day of the report. Given in the layout 2006-01-02.") {
+		t.Errorf("Markdown does not note layout:\n%s", md)
+	}
+
+	schema := string(mustGenerate(t, toml, JSONSchema))
+	for _, s := range []string{`"format": "date-time"`, `"format": "regex"`} {
+		if !strings.Contains(schema, s) {
+			t.Errorf("JSON Schema does not contain %q:\n%s", s, schema)
+		}
+	}
+}
+
+func Test_Generator_Counter(t *testing.T) {
+	toml := `
+	[[flags]]
+	name = "Verbosity"
+	cli = "v"
+	type = "counter"
+	max = 3
+	short_help = "Increase verbosity"
+
+	[[flags]]
+	name = "Quiet"
+	cli = "q"
+	type = "counter"
+	default = 1
+	short_help = "Decrease verbosity"
+	`
+
+	md := string(mustGenerate(t, toml, Markdown))
+	for _, s := range []string{
+		"Increase verbosity. Counts how often it is given, up to 3.",
+		"Decrease verbosity. Counts how often it is given.",
+	} {
+		if !strings.Contains(md, s) {
+			t.Errorf("Markdown does not contain %q:\n%s", s, md)
+		}
+	}
+
+	schema := string(mustGenerate(t, toml, JSONSchema))
+	if !strings.Contains(schema, `"maximum": 3`) {
+		t.Errorf("JSON Schema does not limit counter:\n%s", schema)
+	}
+}
+
+func Test_Generator_CustomTypes(t *testing.T) {
+	toml := `
+	[docs]
+	columns = ["flag", "type", "default", "usage"]
+
+	[[flags]]
+	name = "LogLevel"
+	cli = "log-level"
+	type = "custom"
+	go_type = "slog.Level"
+	import = "log/slog"
+	type_doc = "level"
+	default = "WARN"
+	short_help = "Minimum level of logged messages"
+
+	[[flags]]
+	name = "Since"
+	cli = "since"
+	type = "custom"
+	go_type = "time.Time"
+	import = "time"
+	short_help = "Ignore changes before this time"
+	`
+
+	src := string(mustGenerate(t, toml, Go))
+	mustTypeCheck(t, []byte(src))
+	if strings.Count(src, `"time"`) != 1 {
+		t.Errorf("generated code imports time more than once:\n%s", src)
+	}
+
+	md := string(mustGenerate(t, toml, Markdown))
+	for _, s := range []string{
+		"|log-level|`level`|`WARN`|",
+		"|since|`time.Time`|",
+	} {
+		if !strings.Contains(md, s) {
+			t.Errorf("Markdown does not contain %q:\n%s", s, md)
+		}
+	}
+}
+
+func Test_Generator_DefaultFrom(t *testing.T) {
+	toml := `
+	[docs]
+	columns = ["flag", "default", "usage"]
+
+	[[flags]]
+	name = "NodeID"
+	cli = "node-id"
+	type = "string"
+	default_from = "raft-adv-addr"
+	short_help = "Unique ID for node"
+
+	[[flags]]
+	name = "RaftAddr"
+	cli = "raft-addr"
+	type = "string"
+	default = "localhost:4002"
+	short_help = "Raft communication bind address"
+
+	[[flags]]
+	name = "RaftAdvAddr"
+	cli = "raft-adv-addr"
+	type = "string"
+	default_from = "-raft-addr"
+	short_help = "Advertised Raft communication address"
+
+	[[flags]]
+	name = "Port"
+	cli = "port"
+	type = "int"
+	default = 4001
+	short_help = "Port to listen on"
+
+	[[flags]]
+	name = "AdvPort"
+	cli = "adv-port"
+	type = "int"
+	default_from = "port"
+	short_help = "Advertised port"
+	`
+
+	md := string(mustGenerate(t, toml, Markdown))
+	for _, s := range []string{
+		"|node-id|`same as -raft-adv-addr`|Unique ID for node. Defaults to the value of -raft-adv-addr.|",
+		"|adv-port|`same as -port`|Advertised port. Defaults to the value of -port.|",
+	} {
+		if !strings.Contains(md, s) {
+			t.Errorf("Markdown does not contain %q:\n%s", s, md)
+		}
+	}
+}
+
+func Test_ShellQuote(t *testing.T) {
+	for s, want := range map[string]string{
+		"0.0.0.0:4001": "0.0.0.0:4001",
+		"a,b":          "a,b",
+		"":             "''",
+		"my host":      "'my host'",
+		"it's":         `'it'\''s'`,
+	} {
+		if got := shellQuote(s); got != want {
+			t.Errorf("shellQuote(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+// Test_Generator_SectionsInGoUsage checks that sections group the flags in the
+// generated usage message, and have no other effect on the generated Go code.
+func Test_Generator_SectionsInGoUsage(t *testing.T) {
+	withSections := `
+	[[flags]]
+	name = "NodeID"
+	cli = "node-id"
+	type = "string"
+	default = ""
+	short_help = "Node ID"
+	section = "General"
+
+	[[flags]]
+	name = "HTTPAddr"
+	cli = "http-addr"
+	type = "string"
+	default = "localhost:4001"
+	short_help = "HTTP server bind address"
+	section = "HTTP API"
+
+	[[flags]]
+	name = "ShowVersion"
+	cli = "version"
+	type = "bool"
+	default = false
+	short_help = "Show version information and exit"
+	section = "General"
+	`
+	withoutSections := strings.NewReplacer(
+		"\tsection = \"General\"\n", "",
+		"\tsection = \"HTTP API\"\n", "").Replace(withSections)
+
+	sectioned := mustGenerate(t, withSections, Go)
+	mustTypeCheck(t, sectioned)
+	if !bytes.Contains(sectioned, []byte(`printDefaults(fs, "node-id", "version")
+		fmt.Fprintf(fs.Output(), "\n%s:\n", "HTTP API")
+		printDefaults(fs, "http-addr")`)) {
+		t.Fatalf("sections not grouped in usage message:\n%s", sectioned)
+	}
+
+	unsectioned := mustGenerate(t, withoutSections, Go)
+	if bytes.Contains(unsectioned, []byte("printDefaults")) {
+		t.Fatalf("usage message sectioned without sections:\n%s", unsectioned)
+	}
+
+	// Apart from the usage message, the code is the same.
+	usage := func(src []byte) []byte {
+		if i := bytes.Index(src, []byte("\tfs.Usage = ")); i >= 0 {
+			j := bytes.Index(src[i:], []byte("\n\t}\n"))
+			src = append(src[:i:i], src[i+j+4:]...)
+		}
+		if i := bytes.Index(src, []byte("\n// printDefaults")); i >= 0 {
+			src = src[:i:i]
+		}
+		return src
+	}
+	if !bytes.Equal(bytes.TrimSpace(usage(sectioned)), bytes.TrimSpace(usage(unsectioned))) {
+		t.Fatal("sections changed the generated Go code outside the usage message")
+	}
+}
+
+func Test_Generator_NestSections(t *testing.T) {
+	flags := `
+	[[flags]]
+	name = "NodeID"
+	cli = "node-id"
+	type = "string"
+	short_help = "Node ID"
+	section = "General"
+
+	[[flags]]
+	name = "HTTPAddr"
+	cli = "http-addr"
+	type = "string"
+	default = "localhost:4001"
+	short_help = "HTTP server bind address"
+	section = "HTTP API"
+
+	[[flags]]
+	name = "HTTPHosts"
+	cli = "http-hosts"
+	type = "[]string"
+	short_help = "HTTP hosts"
+	section = "HTTP API"
+	`
+
+	t.Run("DerivedNames", func(t *testing.T) {
+		src := mustGenerate(t, "[go]\nnest_sections = true\n"+flags, Go)
+		mustTypeCheck(t, src)
+		for _, s := range []string{
+			"General GeneralConfig",
+			"HTTPAPI HTTPAPIConfig",
+			"type HTTPAPIConfig struct",
+			"&config.HTTPAPI.HTTPAddr,",
+			"config.HTTPAPI.HTTPHosts = splitString(",
+		} {
+			if !bytes.Contains(src, []byte(s)) {
+				t.Errorf("generated code does not contain %q", s)
+			}
+		}
+	})
+
+	t.Run("OverriddenName", func(t *testing.T) {
+		src := mustGenerate(t, `
+		[go]
+		nest_sections = true
+
+		[[sections]]
+		name = "HTTP API"
+		go_name = "HTTP"
+		`+flags, Go)
+		mustTypeCheck(t, src)
+		if !bytes.Contains(src, []byte("&config.HTTP.HTTPAddr,")) {
+			t.Errorf("section Go name not overridden:\n%s", src)
+		}
+	})
+
+	t.Run("SameNameInTwoSections", func(t *testing.T) {
+		var toml strings.Builder
+		toml.WriteString("[go]\nnest_sections = true\n")
+		for _, section := range []string{"HTTP", "Raft"} {
+			prefix := strings.ToLower(section)
+			fmt.Fprintf(&toml, `
+			[[flags]]
+			name = "Addr"
+			cli = "%[1]s-addr"
+			type = "string"
+			short_help = "Bind address"
+			section = "%[2]s"
+
+			[[flags]]
+			name = "AdvAddr"
+			cli = "%[1]s-adv-addr"
+			type = "string"
+			default_from = "%[1]s-addr"
+			short_help = "Advertised address"
+			section = "%[2]s"
+
+			[[flags]]
+			name = "Peers"
+			cli = "%[1]s-peers"
+			type = "[]string"
+			short_help = "Peers"
+			section = "%[2]s"
+			`, prefix, section)
+		}
+		src := mustGenerate(t, toml.String(), Go)
+		mustTypeCheck(t, src)
+		for _, s := range []string{
+			`fs.StringVar(&config.HTTP.Addr, "http-addr",`,
+			`fs.StringVar(&config.Raft.Addr, "raft-addr",`,
+			"config.HTTP.AdvAddr = config.HTTP.Addr",
+			"config.Raft.AdvAddr = config.Raft.Addr",
+			"config.HTTP.Peers = splitString(tmpHTTPPeers,",
+			"config.Raft.Peers = splitString(tmpRaftPeers,",
+		} {
+			if !bytes.Contains(src, []byte(s)) {
+				t.Errorf("generated code does not contain %q:\n%s", s, src)
+			}
+		}
+	})
+
+	t.Run("SameNameInOneSection", func(t *testing.T) {
+		gen, err := NewGenerator(&ParsedConfig{
+			GoConfig: GoConfig{NestSections: true},
+			Flags: []Flag{
+				{Name: "Addr", CLI: "http-addr", Type: "string", Section: "HTTP"},
+				{Name: "Addr", CLI: "http-adv-addr", Type: "string", Section: "HTTP"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = gen.Execute(Go, new(bytes.Buffer))
+		if err == nil || !strings.Contains(err.Error(), `flags -http-addr and -http-adv-addr of section "HTTP" have the same name Addr`) {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		return buf.Bytes()
+	})
+
+	for name, toml := range map[string]string{
+		"NoSections": `
+		[go]
+		nest_sections = true
+
+		[[flags]]
+		name = "NodeID"
+		cli = "node-id"
+		type = "string"
+		`,
+		"DuplicateName": `
+		[go]
+		nest_sections = true
+
+		[[sections]]
+		name = "HTTP API"
+		go_name = "General"
+		` + flags,
+		"UnusedSection": `
+		[go]
+		nest_sections = true
+
+		[[sections]]
+		name = "Raft"
+		` + flags,
+	} {
+		t.Run(name, func(t *testing.T) {
+			tomlFile := mustWriteToTempTOMLFile(toml)
+			defer os.Remove(tomlFile)
+			cfg, err := NewParser().ParsePath(tomlFile)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			gen, err := NewGenerator(cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := gen.Execute(Go, new(bytes.Buffer)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
 	}
+}
 
-	if !bytes.Equal(generate(withSections), generate(withoutSections)) {
-		t.Fatal("sections changed the generated Go code")
+func Test_GoIdentifier(t *testing.T) {
+	for name, want := range map[string]string{
+		"General":         "General",
+		"HTTP API":        "HTTPAPI",
+		"raft consensus":  "RaftConsensus",
+		"TLS (node-node)": "TLSNodeNode",
+		"2nd":             "",
+		"":                "",
+	} {
+		if got := goIdentifier(name); got != want {
+			t.Errorf("goIdentifier(%q) = %q, want %q", name, got, want)
+		}
 	}
 }
 
@@ -279,6 +1746,105 @@ func Test_GroupBySection(t *testing.T) {
 	})
 }
 
+// mustGenerate parses the given TOML and returns the output generated for it
+// in the given format.
+func mustGenerate(t *testing.T, toml string, f Format) []byte {
+	t.Helper()
+	tomlFile := mustWriteToTempTOMLFile(toml)
+	defer os.Remove(tomlFile)
+
+	cfg, err := NewParser().ParsePath(tomlFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gen, err := NewGenerator(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	if err := gen.Execute(f, buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// mustTypeCheck fails the test if the given generated Go source does not
+// compile.
+func mustTypeCheck(t *testing.T, src []byte) {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "out.go", src, 0)
+	if err != nil {
+		t.Fatalf("failed to parse generated code: %v", err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("pkg", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("generated code does not type-check: %v\n%s", err, src)
+	}
+}
+
+// mustBuildForge builds a program from the Go code generated from the given
+// TOML, returning the path to it. The program prints the config
+// ForgeWithOptions parses from its arguments, or the error it returns. The
+// test is skipped in short mode, or if Go isn't installed.
+func mustBuildForge(t *testing.T, toml string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping build of generated code in short mode")
+	}
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("skipping build of generated code: go not found")
+	}
+
+	tomlFile := mustWriteToTempTOMLFile(toml)
+	defer os.Remove(tomlFile)
+	cfg, err := NewParser().ParsePath(tomlFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.GoConfig.Package = "main"
+	gen, err := NewGenerator(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	src := new(bytes.Buffer)
+	if err := gen.Execute(Go, src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dir := t.TempDir()
+	mustWriteFiles(t, dir, map[string]string{
+		"go.mod": "module forge\n\ngo 1.23\n",
+		"main.go": `package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	_, config, err := ForgeWithOptions(os.Args[1:], WithOutput(io.Discard))
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	fmt.Printf("%+v\n", *config)
+}
+`,
+		"config.go": src.String(),
+	})
+	bin := filepath.Join(dir, "forge")
+	cmd := exec.Command(goCmd, "build", "-o", bin, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build generated code: %v\n%s", err, out)
+	}
+	return bin
+}
+
 func mustWriteToTempTOMLFile(contents string) string {
 	f, err := os.CreateTemp("", "generator_test-*.toml")
 	if err != nil {
@@ -0,0 +1,161 @@
+package flagforge
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// doHelmValues writes a fragment of a Helm chart's values.yaml holding a value
+// for each argument and flag, set to its default. A flag taking its default
+// from another is set to null, leaving it to the program.
+func (g *Generator) doHelmValues(w io.Writer) error {
+	sections, err := groupBySection(g.flags)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	indent := ""
+	for _, key := range strings.Split(g.helm.ValuesKey, ".") {
+		fmt.Fprintf(&buf, "%s%s:\n", indent, key)
+		indent += "  "
+	}
+	writeValue := func(key, desc string, value interface{}) error {
+		for _, line := range wrapText(desc, sampleWidth-len(indent)-2) {
+			buf.WriteString(strings.TrimRight(indent+"# "+line, " ") + "\n")
+		}
+		buf.WriteString(indent + key + ": ")
+		if err := encodeJSON(&buf, value); err != nil {
+			return fmt.Errorf("failed to encode value %s: %w", key, err)
+		}
+		buf.WriteString("\n")
+		return nil
+	}
+
+	for _, arg := range g.args {
+		if err := writeValue(camelCase(arg.Name), arg.ShortHelp, ""); err != nil {
+			return err
+		}
+	}
+	for _, section := range sections {
+		if section.Name != "" {
+			fmt.Fprintf(&buf, "\n%s# %s\n", indent, section.Name)
+		}
+		for _, flag := range section.Flags {
+			if flag.DefaultFrom != "" {
+				def, err := formatDefault(flag)
+				if err != nil {
+					return err
+				}
+				if err := writeValue(camelCase(flag.CLI), flag.ShortHelp+"\nIf null, "+def+".", nil); err != nil {
+					return err
+				}
+				continue
+			}
+			def, err := flagDefault(flag)
+			if err != nil {
+				return err
+			}
+			if err := writeValue(camelCase(flag.CLI), flag.ShortHelp, def); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write Helm values: %w", err)
+	}
+	return nil
+}
+
+// doHelmArgs writes a Helm named template which renders the values written by
+// doHelmValues as a container's args. Include it in a container spec with, for
+// example:
+//
+//	args:
+//	  {{- include "flags.args" . | nindent 12 }}
+//
+// Flags whose value and default are both empty are omitted, and so are flags
+// taking their default from another while their value is null.
+func (g *Generator) doHelmArgs(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "{{- define %q -}}\n", g.helm.TemplateName)
+	for _, flag := range g.flags {
+		def, err := flagDefault(flag)
+		if err != nil {
+			return err
+		}
+		value := fmt.Sprintf(".Values.%s.%s", g.helm.ValuesKey, camelCase(flag.CLI))
+		cli := strings.TrimLeft(flag.CLI, "-")
+		if flag.DefaultFrom != "" {
+			fmt.Fprintf(&buf, "{{- if not (kindIs \"invalid\" %s) }}\n", value)
+		}
+		switch flag.Type {
+		case "bool":
+			fmt.Fprintf(&buf, "- {{ printf \"-%s=%%t\" %s | quote }}\n", cli, value)
+		case "int", "int32", "int64", "uint", "uint16", "uint32", "uint64", "counter":
+			fmt.Fprintf(&buf, "- {{ printf \"-%s=%%d\" (int64 %s) | quote }}\n", cli, value)
+		case "float64":
+			fmt.Fprintf(&buf, "- {{ printf \"-%s=%%v\" (float64 %s) | quote }}\n", cli, value)
+		case "[]string":
+			delim := flag.Delimiter
+			if delim == "" {
+				delim = ","
+			}
+			fmt.Fprintf(&buf, "{{- with %s }}\n", value)
+			fmt.Fprintf(&buf, "- {{ printf \"-%s=%%s\" (join %q .) | quote }}\n", cli, delim)
+			buf.WriteString("{{- end }}\n")
+		default:
+			if def == "" {
+				fmt.Fprintf(&buf, "{{- if %s }}\n", value)
+			}
+			fmt.Fprintf(&buf, "- {{ printf \"-%s=%%s\" %s | quote }}\n", cli, value)
+			if def == "" {
+				buf.WriteString("{{- end }}\n")
+			}
+		}
+		if flag.DefaultFrom != "" {
+			buf.WriteString("{{- end }}\n")
+		}
+	}
+	for _, arg := range g.args {
+		fmt.Fprintf(&buf, "{{- with .Values.%s.%s }}\n", g.helm.ValuesKey, camelCase(arg.Name))
+		buf.WriteString("- {{ . | quote }}\n")
+		buf.WriteString("{{- end }}\n")
+	}
+	buf.WriteString("{{- end }}\n")
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write Helm args template: %w", err)
+	}
+	return nil
+}
+
+// camelCase converts a flag's command-line name, or an argument's name, to the
+// camel case conventionally used for keys in Helm values. "http-addr" becomes
+// "httpAddr", and "HTTPAddr" becomes "httpAddr".
+func camelCase(name string) string {
+	var b strings.Builder
+	for i, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(word)
+		if i > 0 {
+			runes[0] = unicode.ToUpper(runes[0])
+		} else {
+			// Lower the leading run of capitals, other than one which begins
+			// the next word, as the "A" in "HTTPAddr" does.
+			for j := 0; j < len(runes) && unicode.IsUpper(runes[j]); j++ {
+				if j > 0 && j+1 < len(runes) && unicode.IsLower(runes[j+1]) {
+					break
+				}
+				runes[j] = unicode.ToLower(runes[j])
+			}
+		}
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
@@ -0,0 +1,151 @@
+package flagforge
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Test_Generator_Helm checks that the generated args template, given the
+// generated values, renders the arguments that set every flag to its value.
+func Test_Generator_Helm(t *testing.T) {
+	toml := `
+	[helm]
+	values_key = "rqlite.flags"
+
+	[[arguments]]
+	name = "DataPath"
+	type = "string"
+	short_help = "Path to node data"
+
+	[[flags]]
+	name = "HTTPAddr"
+	cli = "http-addr"
+	type = "string"
+	default = "localhost:4001"
+	short_help = "HTTP server bind address"
+
+	[[flags]]
+	name = "HTTPAdvAddr"
+	cli = "http-adv-addr"
+	type = "string"
+	short_help = "Advertised HTTP address"
+
+	[[flags]]
+	name = "RaftSnapThresholdWALSize"
+	cli = "raft-snap-wal-size"
+	type = "uint64"
+	default = 4194304
+	short_help = "Size of a SQLite WAL file which triggers a snapshot"
+
+	[[flags]]
+	name = "RaftSnapThresholdMax"
+	cli = "raft-snap-max"
+	type = "uint64"
+	default_from = "raft-snap-wal-size"
+	short_help = "Most a WAL file may grow to"
+
+	[[flags]]
+	name = "FKConstraints"
+	cli = "fk"
+	type = "bool"
+	short_help = "Enable SQLite foreign key constraints"
+
+	[[flags]]
+	name = "ExtensionPaths"
+	cli = "extensions-path"
+	type = "[]string"
+	delimiter = ";"
+	short_help = "Paths to SQLite extensions"
+	`
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(mustGenerate(t, toml, HelmValues), &values); err != nil {
+		t.Fatalf("generated values are not valid YAML: %v", err)
+	}
+	flags := values["rqlite"].(map[string]interface{})["flags"].(map[string]interface{})
+	if flags["raftSnapWalSize"] != 4194304 {
+		t.Fatalf("wrong default in values: %v", flags)
+	}
+	if v, ok := flags["raftSnapMax"]; !ok || v != nil {
+		t.Fatalf("value taking its default from another is not null: %v", flags)
+	}
+	if s := "    # Most a WAL file may grow to\n    # If null, same as -raft-snap-wal-size.\n    raftSnapMax: null\n"; !strings.Contains(string(mustGenerate(t, toml, HelmValues)), s) {
+		t.Errorf("values do not contain %q", s)
+	}
+
+	args := mustGenerate(t, toml, HelmArgs)
+	render := func() []string {
+		// Stand-ins for the Sprig functions used by the template. Helm decodes
+		// numbers in values as float64, so int64 must accept one.
+		tmpl, err := template.New("args").Funcs(template.FuncMap{
+			"quote": func(s string) string { return fmt.Sprintf("%q", s) },
+			"join": func(sep string, v []interface{}) string {
+				var s []string
+				for _, e := range v {
+					s = append(s, fmt.Sprint(e))
+				}
+				return strings.Join(s, sep)
+			},
+			"int64":  func(v interface{}) int64 { return int64(v.(float64)) },
+			"kindIs": func(kind string, v interface{}) bool { return reflect.ValueOf(v).Kind().String() == kind },
+		}).Parse(string(args) + `{{ template "flags.args" . }}`)
+		if err != nil {
+			t.Fatalf("generated args template is not valid: %v\n%s", err, args)
+		}
+		buf := new(bytes.Buffer)
+		if err := tmpl.Execute(buf, map[string]interface{}{"Values": values}); err != nil {
+			t.Fatalf("failed to render args template: %v", err)
+		}
+		var got []string
+		if err := yaml.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("rendered args are not a YAML list: %v\n%s", err, buf)
+		}
+		return got
+	}
+
+	flags["raftSnapWalSize"] = float64(flags["raftSnapWalSize"].(int))
+	if got, want := render(), []string{
+		"-http-addr=localhost:4001",
+		"-raft-snap-wal-size=4194304",
+		"-fk=false",
+	}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong args for defaults, got %q, want %q", got, want)
+	}
+
+	flags["dataPath"] = "/data"
+	flags["httpAdvAddr"] = "example.com:4001"
+	flags["fk"] = true
+	flags["extensionsPath"] = []interface{}{"a", "b"}
+	flags["raftSnapMax"] = float64(0)
+	if got, want := render(), []string{
+		"-http-addr=localhost:4001",
+		"-http-adv-addr=example.com:4001",
+		"-raft-snap-wal-size=4194304",
+		"-raft-snap-max=0",
+		"-fk=true",
+		"-extensions-path=a;b",
+		"/data",
+	}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong args for values, got %q, want %q", got, want)
+	}
+}
+
+func Test_CamelCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"http-addr":          "httpAddr",
+		"raft-snap-wal-size": "raftSnapWalSize",
+		"fk":                 "fk",
+		"DataPath":           "dataPath",
+		"HTTPAddr":           "httpAddr",
+		"ID":                 "id",
+	} {
+		if got := camelCase(name); got != want {
+			t.Errorf("camelCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package flagforge
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// htmlPageTemplate renders a complete, self-contained HTML page documenting
+// the flags, for those without a static site generator in which to embed the
+// fragment rendered by htmlSectionTemplate. Sections are listed in a table of
+// contents, each flag's row has a stable anchor, and a filter box hides the
+// rows which don't match what is typed in it.
+const htmlPageTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Title | html }}</title>
+<style>
+{{ .Stylesheet }}
+</style>
+</head>
+<body>
+<h1>{{ .Title | html }}</h1>
+<input type="search" id="rq-filter" placeholder="Filter flags" aria-label="Filter flags">
+{{- if .TOC }}
+<nav class="rq-toc">
+<ul>
+{{- range .Sections }}
+<li><a href="#{{ sectionID .Name }}">{{ .Name | html }}</a></li>
+{{- end }}
+</ul>
+</nav>
+{{- end }}
+{{- range .Sections }}
+<section class="rq-section"{{ if .Name }} id="{{ sectionID .Name }}"{{ end }}>
+{{- if .Name }}
+<h2>{{ .Name | html }}</h2>
+{{- end }}
+{{ template "table" . -}}
+</section>
+{{- end }}
+<script>
+document.getElementById("rq-filter").addEventListener("input", function (e) {
+	var query = e.target.value.toLowerCase();
+	document.querySelectorAll("section.rq-section").forEach(function (section) {
+		var shown = 0;
+		section.querySelectorAll("tr[id]").forEach(function (row) {
+			row.hidden = row.textContent.toLowerCase().indexOf(query) < 0;
+			if (!row.hidden) {
+				shown++;
+			}
+		});
+		section.hidden = shown === 0;
+	});
+});
+</script>
+</body>
+</html>
+`
+
+// defaultStylesheet styles the standalone HTML page, unless overridden.
+const defaultStylesheet = `body {
+	font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif;
+	line-height: 1.5;
+	color: #24292f;
+	max-width: 960px;
+	margin: 0 auto;
+	padding: 1em 2em;
+}
+#rq-filter {
+	width: 100%;
+	box-sizing: border-box;
+	padding: 0.5em;
+	margin-bottom: 1em;
+	font-size: 1em;
+}
+.rq-toc ul {
+	padding-left: 1.2em;
+}
+table.rq-flags {
+	width: 100%;
+	border-collapse: collapse;
+	margin-bottom: 2em;
+}
+table.rq-flags th, table.rq-flags td {
+	text-align: left;
+	vertical-align: top;
+	padding: 0.5em;
+	border-bottom: 1px solid #d0d7de;
+}
+table.rq-flags td a {
+	color: inherit;
+	text-decoration: none;
+}
+table.rq-flags tr:target {
+	background: #fff8c5;
+}
+code {
+	font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace;
+	font-size: 0.9em;
+}
+.rq-since {
+	display: inline-block;
+	margin-top: 0.3em;
+	padding: 0 0.5em;
+	font-size: 0.75em;
+	border-radius: 1em;
+	background: #ddf4ff;
+	color: #0969da;
+}`
+
+func (g *Generator) doHTMLPage(w io.Writer) error {
+	sections, err := groupBySection(g.flags)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := g.parseDocTemplate(htmlPageTemplate)
+	if err != nil {
+		return err
+	}
+
+	stylesheet := defaultStylesheet
+	if g.docs.Stylesheet != "" {
+		b, err := os.ReadFile(g.docs.Stylesheet)
+		if err != nil {
+			return fmt.Errorf("failed to read stylesheet: %w", err)
+		}
+		stylesheet = strings.TrimSpace(string(b))
+	}
+	title := g.docs.Title
+	if title == "" {
+		title = g.flagSetName
+	}
+
+	var docSections []docSection
+	for _, s := range sections {
+		docSections = append(docSections, g.docSection(s, true))
+	}
+
+	var output bytes.Buffer
+	if err := tmpl.Execute(&output, struct {
+		Title      string
+		Stylesheet string
+		TOC        bool
+		Sections   []docSection
+	}{
+		Title:      title,
+		Stylesheet: stylesheet,
+		TOC:        sections[0].Name != "",
+		Sections:   docSections,
+	}); err != nil {
+		return fmt.Errorf("failed to execute HTML page template: %w", err)
+	}
+
+	if _, err := w.Write(output.Bytes()); err != nil {
+		return fmt.Errorf("failed to write HTML page: %w", err)
+	}
+	return nil
+}
+
+// flagID returns the id of the given flag's row in the standalone HTML page.
+func flagID(flag Flag) string {
+	return "flag-" + slug(flag.CLI)
+}
+
+// sectionID returns the id of the named section in the standalone HTML page.
+func sectionID(name string) string {
+	return "section-" + slug(name)
+}
+
+// slug lowercases s, and replaces each run of characters other than letters
+// and digits with a single dash. "HTTP API" becomes "http-api".
+func slug(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
+}
@@ -0,0 +1,104 @@
+package flagforge
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Generator_HTMLPage(t *testing.T) {
+	dir := t.TempDir()
+	toml := `
+	[docs]
+	title = "rqlited <flags>"
+
+	[[flags]]
+	name = "NodeID"
+	cli = "node-id"
+	type = "string"
+	short_help = "Unique ID for node"
+	section = "General"
+
+	[[flags]]
+	name = "HTTPAddr"
+	cli = "http-addr"
+	type = "string"
+	default = "localhost:4001"
+	short_help = "HTTP server bind address"
+	section = "HTTP API"
+	`
+
+	page := mustGenerate(t, toml, HTMLPage)
+	for _, s := range []string{
+		"<!DOCTYPE html>",
+		"<title>rqlited &lt;flags&gt;</title>",
+		"table.rq-flags {",
+		`<li><a href="#section-http-api">HTTP API</a></li>`,
+		`<section class="rq-section" id="section-http-api">
+<h2>HTTP API</h2>
+<table class="rq-flags">`,
+		`<tr id="flag-http-addr">
+		<td><a href="#flag-http-addr"><code>-http-addr</code></a></td>`,
+		`<input type="search" id="rq-filter"`,
+		"</table>\n</section>",
+	} {
+		if !bytes.Contains(page, []byte(s)) {
+			t.Errorf("page does not contain %q:\n%s", s, page)
+		}
+	}
+
+	t.Run("NoSections", func(t *testing.T) {
+		page := mustGenerate(t, `
+		[[flags]]
+		name = "NodeID"
+		cli = "node-id"
+		type = "string"
+		short_help = "Unique ID for node"
+		`, HTMLPage)
+		if bytes.Contains(page, []byte("<nav")) || bytes.Contains(page, []byte("<h2>")) {
+			t.Errorf("page without sections has a table of contents:\n%s", page)
+		}
+		if !bytes.Contains(page, []byte(`<tr id="flag-node-id">`)) {
+			t.Errorf("flag is not anchored:\n%s", page)
+		}
+	})
+
+	t.Run("Stylesheet", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(dir, "custom.css"), []byte("body { color: red; }\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		tomlFile := filepath.Join(dir, "in.toml")
+		if err := os.WriteFile(tomlFile, []byte(strings.Replace(toml, "[docs]", "[docs]\nstylesheet = \"custom.css\"", 1)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := NewParser().ParsePath(tomlFile)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		gen, err := NewGenerator(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		buf := new(bytes.Buffer)
+		if err := gen.Execute(HTMLPage, buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte("<style>\nbody { color: red; }\n</style>")) {
+			t.Errorf("stylesheet not overridden:\n%s", buf)
+		}
+	})
+}
+
+func Test_Slug(t *testing.T) {
+	for s, want := range map[string]string{
+		"HTTP API":        "http-api",
+		"-node-id":        "node-id",
+		"TLS (node-node)": "tls-node-node",
+	} {
+		if got := slug(s); got != want {
+			t.Errorf("slug(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
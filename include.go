@@ -0,0 +1,157 @@
+package flagforge
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// includedKeys are the keys which may be set at the top level of an included
+// file. Everything else belongs to the including file alone.
+var includedKeys = []string{"$schema", "include", "arguments", "flag_templates", "flags", "sections"}
+
+// includer merges the files included by a configuration into it.
+type includer struct {
+	parser *Parser
+
+	// stack holds the absolute paths of the files being included, outermost
+	// first, to detect cycles.
+	stack []string
+
+	// seen holds the absolute paths of the files already included, so that a
+	// file included by several others is merged once.
+	seen map[string]bool
+}
+
+func newIncluder(p *Parser) *includer {
+	return &includer{
+		parser: p,
+		seen:   make(map[string]bool),
+	}
+}
+
+// resolve merges the files included by cfg, read from the file at the given
+// path, into cfg, and checks that no two of the resulting flags share a
+// command-line name, or a name within the struct holding their fields: the
+// flag's section if sections are nested, the config struct if not.
+func (in *includer) resolve(cfg *ParsedConfig, path string) error {
+	origins, err := in.include(cfg, path)
+	if err != nil {
+		return err
+	}
+
+	type structName struct {
+		section, name string
+	}
+	names := make(map[structName]int)
+	clis := make(map[string]int)
+	for i, flag := range cfg.Flags {
+		key := structName{name: flag.Name}
+		name := flag.Name
+		if cfg.GoConfig.NestSections && flag.Section != "" {
+			key.section = flag.Section
+			name = fmt.Sprintf("%s of section %q", flag.Name, flag.Section)
+		}
+		if j, ok := names[key]; ok {
+			return duplicateFlagError(name, origins[j], origins[i])
+		}
+		names[key] = i
+		// A flag may be named with or without its leading dash.
+		cli := "-" + strings.TrimLeft(flag.CLI, "-")
+		if j, ok := clis[cli]; ok {
+			return duplicateFlagError(cli, origins[j], origins[i])
+		}
+		clis[cli] = i
+	}
+	return nil
+}
+
+// duplicateFlagError returns the error reporting that the named flag is
+// defined in the files with the given paths.
+func duplicateFlagError(name, first, second string) error {
+	if first == second {
+		return fmt.Errorf("flag %s is defined twice in %s", name, first)
+	}
+	return fmt.Errorf("flag %s is defined in both %s and %s", name, first, second)
+}
+
+// include merges the files included by cfg, read from the file at the given
+// path, into cfg, returning the path of the file which defined each flag.
+func (in *includer) include(cfg *ParsedConfig, path string) ([]string, error) {
+	abs, err := absPath(path)
+	if err != nil {
+		return nil, err
+	}
+	in.stack = append(in.stack, abs)
+	defer func() { in.stack = in.stack[:len(in.stack)-1] }()
+	in.seen[abs] = true
+
+	var (
+		args     []Argument
+		flags    []Flag
+		sections []Section
+		origins  []string
+		includes []string
+	)
+	for _, inc := range cfg.Includes {
+		incPath := resolvePath(path, inc)
+		incAbs, err := absPath(incPath)
+		if err != nil {
+			return nil, err
+		}
+		if i := slices.Index(in.stack, incAbs); i >= 0 {
+			cycle := append(slices.Clone(in.stack[i:]), incAbs)
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
+		if in.seen[incAbs] {
+			continue
+		}
+
+		incCfg, err := in.parser.parseFile(incPath, AutoInput, includedKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to include %s from %s: %w", inc, originName(path), err)
+		}
+		incOrigins, err := in.include(incCfg, incPath)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, incCfg.Arguments...)
+		flags = append(flags, incCfg.Flags...)
+		sections = append(sections, incCfg.Sections...)
+		origins = append(origins, incOrigins...)
+		includes = append(includes, incPath)
+		includes = append(includes, incCfg.Includes...)
+	}
+
+	for range cfg.Flags {
+		origins = append(origins, originName(path))
+	}
+	cfg.Arguments = append(args, cfg.Arguments...)
+	cfg.Flags = append(flags, cfg.Flags...)
+	cfg.Sections = append(sections, cfg.Sections...)
+	cfg.Includes = includes
+	return origins, nil
+}
+
+// absPath returns the absolute form of the given path, or the empty string for
+// input not read from a file.
+func absPath(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	return abs, nil
+}
+
+// originName names the file at the given path, or input not read from a file,
+// in errors.
+func originName(path string) string {
+	if path == "" {
+		return "input"
+	}
+	return path
+}
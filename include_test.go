@@ -0,0 +1,293 @@
+package flagforge
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mustWriteFiles writes the given files, keyed by path relative to dir.
+func mustWriteFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_Parser_Include(t *testing.T) {
+	dir := t.TempDir()
+	mustWriteFiles(t, dir, map[string]string{
+		"common/tls.toml": `
+		include = ["auth.yaml"]
+
+		[[flags]]
+		name = "TLSCert"
+		cli = "tls-cert"
+		type = "string"
+		short_help = "Path to TLS certificate"
+		section = "TLS"
+		`,
+		"common/auth.yaml": `
+flags:
+  - name: AuthFile
+    cli: auth
+    type: string
+    short_help: Path to authentication file
+    section: Auth
+sections:
+  - name: Auth
+    go_name: Authn
+`,
+		"rqlited/flags.toml": `
+		include = ["../common/tls.toml", "../common/auth.yaml"]
+
+		[go]
+		package = "cfg"
+
+		[[flags]]
+		name = "NodeID"
+		cli = "node-id"
+		type = "string"
+		short_help = "Unique ID for node"
+		section = "General"
+		`,
+	})
+
+	cfg, err := NewParser().ParsePath(filepath.Join(dir, "rqlited/flags.toml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, flag := range cfg.Flags {
+		names = append(names, flag.Name)
+	}
+	if got, want := strings.Join(names, ","), "AuthFile,TLSCert,NodeID"; got != want {
+		t.Errorf("flags are %s, want %s", got, want)
+	}
+	if len(cfg.Sections) != 1 || cfg.Sections[0].GoName != "Authn" {
+		t.Errorf("sections are %v", cfg.Sections)
+	}
+	if cfg.GoConfig.Package != "cfg" {
+		t.Errorf("package is %s", cfg.GoConfig.Package)
+	}
+	want := []string{
+		filepath.Join(dir, "rqlited/../common/tls.toml"),
+		filepath.Join(dir, "common/auth.yaml"),
+	}
+	if len(cfg.Includes) != len(want) {
+		t.Fatalf("includes are %v, want %v", cfg.Includes, want)
+	}
+	for i := range want {
+		if cfg.Includes[i] != want[i] {
+			t.Errorf("includes are %v, want %v", cfg.Includes, want)
+		}
+	}
+
+	gen, err := NewGenerator(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	if err := gen.Execute(Go, buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mustTypeCheck(t, buf.Bytes())
+}
+
+func Test_Parser_SameNameInNestedSections(t *testing.T) {
+	dir := t.TempDir()
+	mustWriteFiles(t, dir, map[string]string{
+		"main.toml": `
+		include = ["raft.toml"]
+
+		[go]
+		nest_sections = true
+
+		[[flags]]
+		name = "Addr"
+		cli = "http-addr"
+		type = "string"
+		short_help = "HTTP address"
+		section = "HTTP"
+		`,
+		"raft.toml": `
+		[[flags]]
+		name = "Addr"
+		cli = "raft-addr"
+		type = "string"
+		short_help = "Raft address"
+		section = "Raft"
+		`,
+	})
+	cfg, err := NewParser().ParsePath(filepath.Join(dir, "main.toml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Flags) != 2 {
+		t.Errorf("flags are %v", cfg.Flags)
+	}
+}
+
+func Test_Parser_IncludeErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		files map[string]string
+		err   []string
+	}{
+		"Cycle": {
+			files: map[string]string{
+				"main.toml":  `include = ["a.toml"]`,
+				"a.toml":     `include = ["sub/b.toml"]`,
+				"sub/b.toml": `include = ["../a.toml"]`,
+			},
+			err: []string{"include cycle", "a.toml -> ", "b.toml -> ", "a.toml"},
+		},
+		"DuplicateName": {
+			files: map[string]string{
+				"main.toml": `
+				include = ["tls.toml"]
+
+				[[flags]]
+				name = "TLSCert"
+				cli = "cert"
+				type = "string"
+				short_help = "Certificate"
+				`,
+				"tls.toml": `
+				[[flags]]
+				name = "TLSCert"
+				cli = "tls-cert"
+				type = "string"
+				short_help = "Certificate"
+				`,
+			},
+			err: []string{"flag TLSCert is defined in both", "tls.toml and", "main.toml"},
+		},
+		"DuplicateNameInNestedSection": {
+			files: map[string]string{
+				"main.toml": `
+				include = ["tls.toml"]
+
+				[go]
+				nest_sections = true
+
+				[[flags]]
+				name = "Cert"
+				cli = "cert"
+				type = "string"
+				short_help = "Certificate"
+				section = "TLS"
+				`,
+				"tls.toml": `
+				[[flags]]
+				name = "Cert"
+				cli = "tls-cert"
+				type = "string"
+				short_help = "Certificate"
+				section = "TLS"
+				`,
+			},
+			err: []string{`flag Cert of section "TLS" is defined in both`, "tls.toml and", "main.toml"},
+		},
+		"DuplicateNameInSectionsNotNested": {
+			files: map[string]string{
+				"main.toml": `
+				[[flags]]
+				name = "Addr"
+				cli = "http-addr"
+				type = "string"
+				short_help = "HTTP address"
+				section = "HTTP"
+
+				[[flags]]
+				name = "Addr"
+				cli = "raft-addr"
+				type = "string"
+				short_help = "Raft address"
+				section = "Raft"
+				`,
+			},
+			err: []string{"flag Addr is defined twice in", "main.toml"},
+		},
+		"DuplicateCLI": {
+			files: map[string]string{
+				"main.toml": `
+				include = ["a.toml", "b.toml"]
+				`,
+				"a.toml": `
+				[[flags]]
+				name = "A"
+				cli = "x"
+				type = "bool"
+				short_help = "A"
+				`,
+				"b.toml": `
+				[[flags]]
+				name = "B"
+				cli = "x"
+				type = "bool"
+				short_help = "B"
+				`,
+			},
+			err: []string{"flag -x is defined in both", "a.toml and", "b.toml"},
+		},
+		"DuplicateCLIWithDash": {
+			files: map[string]string{
+				"main.toml": `
+				include = ["a.toml"]
+
+				[[flags]]
+				name = "NodeID"
+				cli = "-node-id"
+				type = "string"
+				short_help = "Node ID"
+				`,
+				"a.toml": `
+				[[flags]]
+				name = "ID"
+				cli = "node-id"
+				type = "string"
+				short_help = "ID"
+				`,
+			},
+			err: []string{"flag -node-id is defined in both", "a.toml and", "main.toml"},
+		},
+		"IncludedGoConfig": {
+			files: map[string]string{
+				"main.toml": `include = ["a.toml"]`,
+				"a.toml": `
+				[go]
+				package = "cfg"
+				`,
+			},
+			err: []string{"failed to include a.toml", "unsupported keys: go"},
+		},
+		"Missing": {
+			files: map[string]string{
+				"main.toml": `include = ["missing.toml"]`,
+			},
+			err: []string{"failed to include missing.toml"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			mustWriteFiles(t, dir, tt.files)
+			_, err := NewParser().ParsePath(filepath.Join(dir, "main.toml"))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			for _, s := range tt.err {
+				if !strings.Contains(err.Error(), s) {
+					t.Errorf("error %q does not contain %q", err, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package flagforge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// durationPattern matches the strings accepted by time.ParseDuration.
+const durationPattern = `^[-+]?(0|(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|μs|ms|s|m|h))+)$`
+
+// jsonSchema is the root of a JSON Schema describing a configuration file
+// which sets the flags.
+type jsonSchema struct {
+	Schema               string               `json:"$schema"`
+	Title                string               `json:"title,omitempty"`
+	Type                 string               `json:"type"`
+	Properties           jsonSchemaProperties `json:"properties"`
+	AdditionalProperties bool                 `json:"additionalProperties"`
+}
+
+// jsonSchemaProperty describes a single flag. Default is not omitted when
+// empty, since false, 0 and "" are all meaningful defaults.
+type jsonSchemaProperty struct {
+	Type        string           `json:"type"`
+	Description string           `json:"description,omitempty"`
+	Default     interface{}      `json:"default"`
+	Items       *jsonSchemaItems `json:"items,omitempty"`
+	Minimum     *int64           `json:"minimum,omitempty"`
+	Maximum     *int64           `json:"maximum,omitempty"`
+	Pattern     string           `json:"pattern,omitempty"`
+	Format      string           `json:"format,omitempty"`
+}
+
+// jsonSchemaItems describes the elements of an array property.
+type jsonSchemaItems struct {
+	Type string `json:"type"`
+}
+
+// jsonSchemaProperties holds properties in the order the flags are declared,
+// which a map would lose.
+type jsonSchemaProperties []namedJSONSchemaProperty
+
+type namedJSONSchemaProperty struct {
+	Name     string
+	Property jsonSchemaProperty
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p jsonSchemaProperties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, prop := range p {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		if err := encodeJSON(&buf, prop.Name); err != nil {
+			return nil, err
+		}
+		buf.WriteString(":")
+		if err := encodeJSON(&buf, prop.Property); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+func (g *Generator) doJSONSchema(w io.Writer) error {
+	schema := jsonSchema{
+		Schema:     "https://json-schema.org/draft/2020-12/schema",
+		Title:      g.flagSetName,
+		Type:       "object",
+		Properties: jsonSchemaProperties{},
+	}
+	for _, flag := range g.flags {
+		prop, err := jsonSchemaFlag(flag)
+		if err != nil {
+			return err
+		}
+		schema.Properties = append(schema.Properties, namedJSONSchemaProperty{
+			Name:     strings.TrimLeft(flag.CLI, "-"),
+			Property: prop,
+		})
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(schema); err != nil {
+		return fmt.Errorf("failed to encode JSON Schema: %w", err)
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write JSON Schema: %w", err)
+	}
+	return nil
+}
+
+// jsonSchemaFlag returns the JSON Schema property describing the given flag.
+func jsonSchemaFlag(flag Flag) (jsonSchemaProperty, error) {
+	def, err := flagDefault(flag)
+	if err != nil {
+		return jsonSchemaProperty{}, err
+	}
+	prop := jsonSchemaProperty{
+		Description: flagDescription(flag),
+		Default:     def,
+	}
+	switch flag.Type {
+	case "string", "filepath", "hostport", "ip", "cidr", "custom":
+		prop.Type = "string"
+	case "url":
+		prop.Type = "string"
+		prop.Format = "uri"
+	case "time.Time":
+		prop.Type = "string"
+		if flag.Layout == "" || flag.Layout == time.RFC3339 {
+			prop.Format = "date-time"
+		}
+	case "regexp":
+		prop.Type = "string"
+		prop.Format = "regex"
+	case "bool":
+		prop.Type = "boolean"
+	case "int", "int64":
+		prop.Type = "integer"
+	case "uint", "uint64":
+		prop.Type = "integer"
+		prop.Minimum = new(int64)
+	case "int32", "uint16", "uint32":
+		r := intRanges[flag.Type]
+		prop.Type = "integer"
+		prop.Minimum, prop.Maximum = &r[0], &r[1]
+	case "counter":
+		prop.Type = "integer"
+		prop.Minimum = new(int64)
+		if flag.Max > 0 {
+			max := int64(flag.Max)
+			prop.Maximum = &max
+		}
+	case "float64":
+		prop.Type = "number"
+	case "time.Duration":
+		prop.Type = "string"
+		prop.Pattern = durationPattern
+	case "[]string":
+		prop.Type = "array"
+		prop.Items = &jsonSchemaItems{Type: "string"}
+	default:
+		return jsonSchemaProperty{}, fmt.Errorf("flag %s has unsupported type %s", flag.Name, flag.Type)
+	}
+	return prop, nil
+}
+
+// flagDescription returns a flag's short help, followed by its long help if it
+// has any.
+func flagDescription(flag Flag) string {
+	desc := strings.TrimSpace(flag.ShortHelp)
+	if long := strings.TrimSpace(flag.LongHelp); long != "" {
+		if desc != "" && !strings.HasSuffix(desc, ".") {
+			desc += "."
+		}
+		desc += "\n\n" + long
+	}
+	return desc
+}
+
+// encodeJSON writes v to w as JSON, without escaping HTML characters.
+func encodeJSON(w io.Writer, v interface{}) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return err
+}
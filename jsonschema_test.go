@@ -0,0 +1,107 @@
+package flagforge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func Test_Generator_JSONSchemaGoldenFiles(t *testing.T) {
+	for _, f := range []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "multi-flag/in.toml",
+			out: "multi-flag/out.schema.json",
+		},
+	} {
+		in := "testdata/" + f.in
+		out := "testdata/" + f.out
+
+		parser := NewParser()
+		cfg, err := parser.ParsePath(in)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		gen, err := NewGenerator(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		buf := new(bytes.Buffer)
+		if err := gen.Execute(JSONSchema, buf); err != nil {
+			t.Fatalf("unexpected error testing %s: %v", in, err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), mustReadFile(out)) {
+			t.Errorf("generated output does not match %s\n", out)
+			fmt.Println(buf.String())
+			t.Fatal()
+		}
+	}
+}
+
+func Test_Generator_JSONSchema(t *testing.T) {
+	src := mustGenerate(t, `
+	[[flags]]
+	name = "RaftSnapThreshold"
+	cli = "raft-snap"
+	type = "uint64"
+	default = 8192
+	short_help = "Number of outstanding log entries that trigger a snapshot"
+
+	[[flags]]
+	name = "FKConstraints"
+	cli = "fk"
+	type = "bool"
+	short_help = "Enable SQLite foreign key constraints"
+
+	[[flags]]
+	name = "JoinInterval"
+	cli = "join-interval"
+	type = "time.Duration"
+	default = "3s"
+	short_help = "Time between retrying failed join operations"
+	long_help = "Use <b>3s</b> or more."
+	`, JSONSchema)
+
+	var schema struct {
+		Type       string
+		Properties map[string]struct {
+			Type        string
+			Description string
+			Default     interface{}
+			Minimum     *int
+			Pattern     string
+		}
+		AdditionalProperties bool
+	}
+	if err := json.Unmarshal(src, &schema); err != nil {
+		t.Fatalf("generated schema is not valid JSON: %v\n%s", err, src)
+	}
+	if schema.Type != "object" || schema.AdditionalProperties {
+		t.Fatalf("schema does not describe a closed object:\n%s", src)
+	}
+
+	snap := schema.Properties["raft-snap"]
+	if snap.Type != "integer" || snap.Default != float64(8192) || snap.Minimum == nil || *snap.Minimum != 0 {
+		t.Errorf("wrong property for uint64 flag: %+v", snap)
+	}
+	fk := schema.Properties["fk"]
+	if fk.Type != "boolean" || fk.Default != false {
+		t.Errorf("wrong property for bool flag: %+v", fk)
+	}
+	interval := schema.Properties["join-interval"]
+	if interval.Type != "string" || interval.Default != "3s" || interval.Pattern != durationPattern {
+		t.Errorf("wrong property for time.Duration flag: %+v", interval)
+	}
+	if want := "Time between retrying failed join operations.\n\nUse <b>3s</b> or more."; interval.Description != want {
+		t.Errorf("wrong description, got %q, want %q", interval.Description, want)
+	}
+	if !bytes.Contains(src, []byte("<b>3s</b>")) {
+		t.Errorf("description was HTML-escaped:\n%s", src)
+	}
+}
@@ -1,9 +1,15 @@
 package flagforge
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
@@ -14,6 +20,89 @@ type GoConfig struct {
 	FlagSetUsage      string `mapstructure:"flag_set_usage"`
 	FlagSetName       string `mapstructure:"flag_set_name"`
 	FlagErrorHandling string `mapstructure:"flag_error_handling"`
+
+	// NestSections places the fields of each section's flags in a struct of
+	// their own, itself a field of the config struct.
+	NestSections bool `mapstructure:"nest_sections"`
+}
+
+// DocsConfig represents the configuration for the generated Markdown and HTML
+// documentation.
+type DocsConfig struct {
+	// Columns lists the columns of each table of flags, in order. Allowed
+	// columns are "flag", "usage", "type", "default", "env" and "section".
+	Columns []string `mapstructure:"columns"`
+
+	// MarkdownHelp treats each flag's long help as CommonMark, rendering it
+	// as HTML in the generated HTML documentation.
+	MarkdownHelp bool `mapstructure:"markdown_help"`
+
+	// Title is the title of the standalone HTML page. If not set, it is the
+	// flag set's name.
+	Title string `mapstructure:"title"`
+
+	// Stylesheet is the path to a CSS file replacing the standalone HTML
+	// page's default stylesheet. A relative path is relative to the file
+	// which sets it.
+	Stylesheet string `mapstructure:"stylesheet"`
+}
+
+// HelmConfig represents the configuration for the generated Helm chart files.
+type HelmConfig struct {
+	// ValuesKey is the key, or dot-separated path of keys, under which the
+	// flags are set in the chart's values.
+	ValuesKey string `mapstructure:"values_key"`
+
+	// TemplateName is the name of the template rendering a container's args.
+	TemplateName string `mapstructure:"template_name"`
+}
+
+// EnvConfig represents the configuration of the environment variables which
+// may set the flags.
+type EnvConfig struct {
+	// Prefix is prepended to the name of every variable.
+	Prefix string `mapstructure:"prefix"`
+}
+
+// SystemdConfig represents the configuration for the generated systemd files.
+type SystemdConfig struct {
+	// ExecStart is the path to the program. If not set, it is the flag set's
+	// name in /usr/bin.
+	ExecStart string `mapstructure:"exec_start"`
+}
+
+// TemplatesConfig represents the paths to templates replacing the built-in
+// ones. A relative path is relative to the file which sets it.
+type TemplatesConfig struct {
+	Go       string `mapstructure:"go"`
+	Markdown string `mapstructure:"markdown"`
+	HTML     string `mapstructure:"html"`
+}
+
+// Output represents a file generated by Generator.Build. Relative paths are
+// relative to the file which declares the output.
+type Output struct {
+	// Format is the name of the output's format, as accepted by ParseFormat.
+	Format string `mapstructure:"format"`
+
+	// Path is the path of the generated file.
+	Path string `mapstructure:"path"`
+
+	// Header is the path to a file copied to the output before the generated
+	// content.
+	Header string `mapstructure:"header"`
+
+	// Template is the path to a template replacing the built-in one.
+	Template string `mapstructure:"template"`
+}
+
+// Manifest represents a build manifest: a file declaring the outputs generated
+// from a configuration file kept elsewhere.
+type Manifest struct {
+	// Input is the path to the configuration file.
+	Input string `mapstructure:"input"`
+
+	Outputs []Output `mapstructure:"outputs"`
 }
 
 // Argument represents a single argument configuration.
@@ -35,41 +124,274 @@ type Flag struct {
 	ShortHelp string      `mapstructure:"short_help"`
 	LongHelp  string      `mapstructure:"long_help"`
 
-	// Section groups the flag with others in the generated documentation. It is
-	// ignored by the Go generator.
+	// Section groups the flag with others in the generated documentation, and
+	// in the usage message of the generated Go code.
 	Section string `mapstructure:"section"`
+
+	// Examples are values of the flag shown in the generated documentation.
+	Examples []string `mapstructure:"examples"`
+
+	// Since is the version in which the flag was introduced.
+	Since string `mapstructure:"since"`
+
+	// DefaultFrom names, by its command-line name, another flag of the same
+	// type whose value the generated code gives this flag if it isn't set.
+	DefaultFrom string `mapstructure:"default_from"`
+
+	// Layout applies to time.Time flags alone, and is the layout, as
+	// understood by time.Parse, in which they are given. If empty it is
+	// time.RFC3339.
+	Layout string `mapstructure:"layout"`
+
+	// Max applies to counter flags alone, and if positive is the most their
+	// count may reach.
+	Max int `mapstructure:"max"`
+
+	// GoType, Import and TypeDoc apply to custom flags alone. GoType is the
+	// type of the flag's field, whose pointer must implement
+	// encoding.TextUnmarshaler and which must implement
+	// encoding.TextMarshaler, Import the path of the package declaring it, if
+	// any, and TypeDoc the name of the type in the documentation, GoType if
+	// empty.
+	GoType  string `mapstructure:"go_type"`
+	Import  string `mapstructure:"import"`
+	TypeDoc string `mapstructure:"type_doc"`
+
+	// The remaining options apply to filepath flags alone, and are applied
+	// by the generated code after the flags are parsed, in this order, to
+	// paths which are set.
+
+	// ExpandHome replaces a leading "~" with the user's home directory.
+	ExpandHome bool `mapstructure:"expand_home"`
+
+	// MakeAbsolute makes a relative path absolute.
+	MakeAbsolute bool `mapstructure:"make_absolute"`
+
+	// CreateDir creates the directory at the path, or the directory holding
+	// it if Kind is "file", if it doesn't exist.
+	CreateDir bool `mapstructure:"create_dir"`
+
+	// MustExist requires the path to exist and be readable.
+	MustExist bool `mapstructure:"must_exist"`
+
+	// Kind requires the path, if it exists, to be a "file" or a "dir".
+	Kind string `mapstructure:"kind"`
+}
+
+// Section represents the configuration of a section. Declaring a section is
+// only necessary to override its defaults.
+type Section struct {
+	Name string `mapstructure:"name"`
+
+	// GoName names the section's field, and prefixes the name of its struct
+	// type, when sections are nested in the generated Go code. If not set it is
+	// derived from the section's name.
+	GoName string `mapstructure:"go_name"`
 }
 
 type ParsedConfig struct {
-	GoConfig  GoConfig
+	GoConfig      GoConfig
+	DocsConfig    DocsConfig
+	HelmConfig    HelmConfig
+	EnvConfig     EnvConfig
+	SystemdConfig SystemdConfig
+	Templates     TemplatesConfig
+	Outputs       []Output
+
+	// Includes lists the files included, directly or indirectly, whose
+	// arguments, flags and sections precede the including file's own.
+	Includes []string
+
 	Arguments []Argument
 	Flags     []Flag
+	Sections  []Section
+}
+
+// InputFormat is the format of the file describing the flags.
+type InputFormat int
+
+const (
+	// AutoInput detects the format from a file's extension: .yaml or .yml for
+	// YAML, .json for JSON, and TOML otherwise. Input read by ParseReader is
+	// TOML.
+	AutoInput InputFormat = iota
+	TOMLInput
+	YAMLInput
+	JSONInput
+)
+
+// String returns the string representation of the input format.
+func (f InputFormat) String() string {
+	switch f {
+	case AutoInput:
+		return "auto"
+	case TOMLInput:
+		return "TOML"
+	case YAMLInput:
+		return "YAML"
+	case JSONInput:
+		return "JSON"
+	default:
+		return "Unknown"
+	}
+}
+
+// ParseInputFormat returns the input format with the given name: "auto",
+// "toml", "yaml" or "json".
+func ParseInputFormat(name string) (InputFormat, error) {
+	switch strings.ToLower(name) {
+	case "auto":
+		return AutoInput, nil
+	case "toml":
+		return TOMLInput, nil
+	case "yaml", "yml":
+		return YAMLInput, nil
+	case "json":
+		return JSONInput, nil
+	default:
+		return 0, fmt.Errorf("unknown input format: %s", name)
+	}
 }
 
+// configType returns viper's name for the input format, detecting it from the
+// given path if necessary.
+func (f InputFormat) configType(path string) string {
+	if f == AutoInput {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			f = YAMLInput
+		case ".json":
+			f = JSONInput
+		default:
+			f = TOMLInput
+		}
+	}
+	return strings.ToLower(f.String())
+}
+
+// Parser parses the file describing the flags.
 type Parser struct {
+	format InputFormat
 }
 
+// NewParser returns a parser which detects the format of its input.
 func NewParser() *Parser {
 	return &Parser{}
 }
 
+// SetInputFormat sets the format of the parser's input.
+func (p *Parser) SetInputFormat(f InputFormat) {
+	p.format = f
+}
+
+// ParsePath parses the file at the given path, and the files it includes.
+// Relative paths set in the file are made relative to its directory.
 func (p *Parser) ParsePath(path string) (*ParsedConfig, error) {
+	cfg, err := p.parseFile(path, p.format, topLevelKeys)
+	if err != nil {
+		return nil, err
+	}
+	if err := newIncluder(p).resolve(cfg, path); err != nil {
+		return nil, err
+	}
+	cfg.DocsConfig.Stylesheet = resolvePath(path, cfg.DocsConfig.Stylesheet)
+	cfg.Templates.Go = resolvePath(path, cfg.Templates.Go)
+	cfg.Templates.Markdown = resolvePath(path, cfg.Templates.Markdown)
+	cfg.Templates.HTML = resolvePath(path, cfg.Templates.HTML)
+	resolveOutputs(path, cfg.Outputs)
+	return cfg, nil
+}
+
+// ParseManifest parses the YAML build manifest at the given path.
+func (p *Parser) ParseManifest(path string) (*Manifest, error) {
 	v := getViper()
 	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
 	if err := v.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("failed to read TOML file at %s: %w", path, err)
+		return nil, fmt.Errorf("failed to read manifest at %s: %w", path, err)
 	}
-	return parseConfig(v)
+	var m Manifest
+	if err := v.Unmarshal(&m, strict); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+	if m.Input == "" {
+		return nil, fmt.Errorf("manifest at %s has no input", path)
+	}
+	m.Input = resolvePath(path, m.Input)
+	resolveOutputs(path, m.Outputs)
+	return &m, nil
 }
 
+// ParseReader parses the input read from r, and the files it includes.
+// Relative paths set in the input are left relative to the current directory.
 func (p *Parser) ParseReader(r io.Reader) (*ParsedConfig, error) {
+	cfg, err := p.parse(r, p.format.configType(""), topLevelKeys)
+	if err != nil {
+		return nil, err
+	}
+	if err := newIncluder(p).resolve(cfg, ""); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// parseFile parses the file at the given path, in the given format, allowing
+// the given top-level keys.
+func (p *Parser) parseFile(path string, f InputFormat, keys []string) (*ParsedConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file at %s: %w", path, err)
+	}
+	cfg, err := p.parse(bytes.NewReader(b), f.configType(path), keys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// parse parses input of the given viper config type read from r, allowing the
+// given top-level keys.
+func (p *Parser) parse(r io.Reader, configType string, keys []string) (*ParsedConfig, error) {
 	v := getViper()
-	if err := viper.ReadConfig(r); err != nil {
-		return nil, fmt.Errorf("failed to read TOML from reader: %w", err)
+	v.SetConfigType(configType)
+	if err := v.ReadConfig(r); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", strings.ToUpper(configType), err)
+	}
+	if err := checkKeys(v, keys); err != nil {
+		return nil, err
 	}
 	return parseConfig(v)
 }
 
+// topLevelKeys are the keys which may be set at the top level of the input.
+// A JSON file names its schema with "$schema".
+var topLevelKeys = []string{
+	"$schema", "include", "go", "docs", "helm", "env", "systemd", "templates",
+	"outputs", "arguments", "flag_templates", "flags", "sections",
+}
+
+// checkKeys returns an error if the input sets a top-level key other than the
+// given ones. Keys within tables are checked as they are unmarshalled.
+func checkKeys(v *viper.Viper, keys []string) error {
+	var unknown []string
+	for key := range v.AllSettings() {
+		if !slices.Contains(keys, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("unsupported keys: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// strict makes unmarshalling fail if the input sets a key with no matching
+// field, so that a misspelt key is reported rather than silently ignored.
+func strict(c *mapstructure.DecoderConfig) {
+	c.ErrorUnused = true
+}
+
 func parseConfig(v *viper.Viper) (*ParsedConfig, error) {
 	goConfig := GoConfig{
 		Package:           "pkg",
@@ -78,25 +400,103 @@ func parseConfig(v *viper.Viper) (*ParsedConfig, error) {
 		FlagErrorHandling: "ExitOnError",
 	}
 
-	if err := v.UnmarshalKey("go", &goConfig); err != nil {
+	if err := v.UnmarshalKey("go", &goConfig, strict); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal go config: %w", err)
 	}
 
+	docsConfig := DocsConfig{
+		Columns: []string{"flag", "usage"},
+	}
+	if err := v.UnmarshalKey("docs", &docsConfig, strict); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal docs config: %w", err)
+	}
+
+	helmConfig := HelmConfig{
+		ValuesKey:    "flags",
+		TemplateName: "flags.args",
+	}
+	if err := v.UnmarshalKey("helm", &helmConfig, strict); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal helm config: %w", err)
+	}
+
+	var envConfig EnvConfig
+	if err := v.UnmarshalKey("env", &envConfig, strict); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal env config: %w", err)
+	}
+	var systemdConfig SystemdConfig
+	if err := v.UnmarshalKey("systemd", &systemdConfig, strict); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal systemd config: %w", err)
+	}
+	var templates TemplatesConfig
+	if err := v.UnmarshalKey("templates", &templates, strict); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal templates config: %w", err)
+	}
+
+	var outputs []Output
+	if err := v.UnmarshalKey("outputs", &outputs, strict); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal outputs: %w", err)
+	}
+
+	var include []string
+	if err := v.UnmarshalKey("include", &include, strict); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal include: %w", err)
+	}
+
 	var args []Argument
-	if err := v.UnmarshalKey("arguments", &args); err != nil {
+	if err := v.UnmarshalKey("arguments", &args, strict); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal arguments: %w", err)
 	}
-	var flags []Flag
-	if err := v.UnmarshalKey("flags", &flags); err != nil {
+	var flagTemplates []FlagTemplate
+	if err := v.UnmarshalKey("flag_templates", &flagTemplates, strict); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal flag templates: %w", err)
+	}
+	flags, err := expandFlagTemplates(flagTemplates)
+	if err != nil {
+		return nil, err
+	}
+	var ownFlags []Flag
+	if err := v.UnmarshalKey("flags", &ownFlags, strict); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal flags: %w", err)
 	}
+	flags = append(flags, ownFlags...)
+	var sections []Section
+	if err := v.UnmarshalKey("sections", &sections, strict); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sections: %w", err)
+	}
 	return &ParsedConfig{
-		GoConfig:  goConfig,
-		Arguments: args,
-		Flags:     flags,
+		GoConfig:      goConfig,
+		DocsConfig:    docsConfig,
+		HelmConfig:    helmConfig,
+		EnvConfig:     envConfig,
+		SystemdConfig: systemdConfig,
+		Templates:     templates,
+		Outputs:       outputs,
+		Includes:      include,
+		Arguments:     args,
+		Flags:         flags,
+		Sections:      sections,
 	}, nil
 }
 
+// resolvePath resolves a path set in the file at the given path. A relative
+// path is made relative to the directory holding that file.
+func resolvePath(from, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(from), path)
+}
+
+// resolveOutputs resolves the paths of the given outputs, declared in the file
+// at the given path.
+func resolveOutputs(from string, outputs []Output) {
+	for i := range outputs {
+		outputs[i].Path = resolvePath(from, outputs[i].Path)
+		outputs[i].Header = resolvePath(from, outputs[i].Header)
+		outputs[i].Template = resolvePath(from, outputs[i].Template)
+	}
+}
+
 func getViper() *viper.Viper {
 	return viper.New()
 }
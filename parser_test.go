@@ -0,0 +1,189 @@
+package flagforge
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_Parser_InputFormats(t *testing.T) {
+	inputs := map[string]string{
+		"flags.toml": `
+		[go]
+		package = "cfg"
+
+		[[flags]]
+		name = "Timeout"
+		cli = "timeout"
+		type = "time.Duration"
+		default = "5s"
+		short_help = "Request timeout"
+		examples = ["10s"]
+
+		[[flags]]
+		name = "MaxBytes"
+		cli = "max-bytes"
+		type = "int64"
+		default = 1048576
+		short_help = "Maximum request size"
+		`,
+		"flags.yaml": `
+go:
+  package: cfg
+flags:
+  - name: Timeout
+    cli: timeout
+    type: time.Duration
+    default: 5s
+    short_help: Request timeout
+    examples: [10s]
+  - name: MaxBytes
+    cli: max-bytes
+    type: int64
+    default: 1048576
+    short_help: Maximum request size
+`,
+		"flags.json": `{
+			"$schema": "./flagforge.schema.json",
+			"go": {"package": "cfg"},
+			"flags": [{
+				"name": "Timeout",
+				"cli": "timeout",
+				"type": "time.Duration",
+				"default": "5s",
+				"short_help": "Request timeout",
+				"examples": ["10s"]
+			}, {
+				"name": "MaxBytes",
+				"cli": "max-bytes",
+				"type": "int64",
+				"default": 1048576,
+				"short_help": "Maximum request size"
+			}]
+		}`,
+	}
+
+	// Each format decodes numbers as types of its own, so defaults are
+	// compared as the generator reads them.
+	normalize := func(name string, cfg *ParsedConfig) {
+		for i, flag := range cfg.Flags {
+			def, err := flagDefault(flag)
+			if err != nil {
+				t.Fatalf("unexpected error reading default of %s: %v", name, err)
+			}
+			cfg.Flags[i].Default = def
+		}
+	}
+
+	generate := func(name string, cfg *ParsedConfig) string {
+		gen, err := NewGenerator(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error generating from %s: %v", name, err)
+		}
+		buf := new(bytes.Buffer)
+		if err := gen.Execute(Go, buf); err != nil {
+			t.Fatalf("unexpected error generating from %s: %v", name, err)
+		}
+		return buf.String()
+	}
+
+	dir := t.TempDir()
+	var want *ParsedConfig
+	var wantGo string
+	for _, name := range []string{"flags.toml", "flags.yaml", "flags.json"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(inputs[name]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := NewParser().ParsePath(path)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %s: %v", name, err)
+		}
+		src := generate(name, cfg)
+		normalize(name, cfg)
+		if want == nil {
+			want, wantGo = cfg, src
+			continue
+		}
+		if src != wantGo {
+			t.Errorf("%s generated Go:\n%s\nwant:\n%s", name, src, wantGo)
+		}
+		if !reflect.DeepEqual(cfg, want) {
+			t.Errorf("%s parsed as %+v, want %+v", name, cfg, want)
+		}
+
+		// Parsed from a reader, the format must be given.
+		p := NewParser()
+		if _, err := p.ParseReader(strings.NewReader(inputs[name])); err == nil {
+			t.Errorf("%s parsed from reader as TOML", name)
+		}
+		f, err := ParseInputFormat(strings.TrimPrefix(filepath.Ext(name), "."))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		p.SetInputFormat(f)
+		cfg, err = p.ParseReader(strings.NewReader(inputs[name]))
+		if err != nil {
+			t.Fatalf("unexpected error parsing %s from reader: %v", name, err)
+		}
+		normalize(name, cfg)
+		if !reflect.DeepEqual(cfg, want) {
+			t.Errorf("%s parsed from reader as %+v, want %+v", name, cfg, want)
+		}
+	}
+
+	if want.GoConfig.Package != "cfg" || len(want.Flags) != 2 || want.Flags[0].Default != "5s" || want.Flags[1].Default != int64(1048576) {
+		t.Errorf("TOML parsed as %+v", want)
+	}
+}
+
+func Test_Parser_ReaderIsolated(t *testing.T) {
+	for _, pkg := range []string{"one", "two"} {
+		cfg, err := NewParser().ParseReader(strings.NewReader("[go]\npackage = \"" + pkg + "\"\n"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.GoConfig.Package != pkg {
+			t.Errorf("package is %s, want %s", cfg.GoConfig.Package, pkg)
+		}
+	}
+}
+
+func Test_Parser_Strict(t *testing.T) {
+	for name, toml := range map[string]string{
+		"TopLevel": "[gooo]\npackage = \"cfg\"\n",
+		"Table":    "[go]\npackge = \"cfg\"\n",
+		"Flag":     "[[flags]]\nname = \"A\"\ncli = \"a\"\ntype = \"bool\"\nshort_hlep = \"A\"\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewParser().ParseReader(strings.NewReader(toml))
+			if err == nil {
+				t.Fatal("expected error for unknown key")
+			}
+		})
+	}
+}
+
+func Test_ParseInputFormat(t *testing.T) {
+	for name, want := range map[string]InputFormat{
+		"auto": AutoInput,
+		"toml": TOMLInput,
+		"YAML": YAMLInput,
+		"yml":  YAMLInput,
+		"json": JSONInput,
+	} {
+		got, err := ParseInputFormat(name)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %s: %v", name, err)
+		}
+		if got != want {
+			t.Errorf("ParseInputFormat(%q) = %s, want %s", name, got, want)
+		}
+	}
+	if _, err := ParseInputFormat("hcl"); err == nil {
+		t.Error("expected error parsing unknown input format")
+	}
+}
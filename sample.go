@@ -0,0 +1,82 @@
+package flagforge
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// sampleWidth is the width to which comments in sample configuration files
+// are wrapped.
+const sampleWidth = 80
+
+// doSample writes a sample configuration file setting every flag to its
+// default, documented by its help text. TOML and YAML differ only in how a key
+// is separated from its value, since both accept JSON strings and arrays.
+func (g *Generator) doSample(w io.Writer, sep string) error {
+	sections, err := groupBySection(g.flags)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, section := range sections {
+		if section.Name != "" {
+			writeSectionComment(&buf, section.Name)
+		}
+		for _, flag := range section.Flags {
+			def, err := flagDefault(flag)
+			if err != nil {
+				return err
+			}
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			for _, line := range wrapText(flagDescription(flag), sampleWidth-2) {
+				buf.WriteString(strings.TrimSpace("# "+line) + "\n")
+			}
+			buf.WriteString(strings.TrimLeft(flag.CLI, "-") + sep)
+			if err := encodeJSON(&buf, def); err != nil {
+				return fmt.Errorf("failed to encode default of flag %s: %w", flag.Name, err)
+			}
+			buf.WriteString("\n")
+		}
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write sample configuration: %w", err)
+	}
+	return nil
+}
+
+// writeSectionComment writes a comment introducing the named section, set off
+// by a blank line from anything already written.
+func writeSectionComment(buf *bytes.Buffer, name string) {
+	if buf.Len() > 0 {
+		buf.WriteString("\n")
+	}
+	rule := "# " + strings.Repeat("-", sampleWidth-2) + "\n"
+	fmt.Fprintf(buf, "%s# %s\n%s", rule, name, rule)
+}
+
+// wrapText breaks text into lines no longer than width, unless a single word is
+// longer. Line breaks already in the text are kept.
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, para := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			if line != "" && len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,133 @@
+package flagforge
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func Test_Generator_SampleGoldenFiles(t *testing.T) {
+	for _, f := range []struct {
+		in     string
+		out    string
+		format Format
+	}{
+		{
+			in:     "sections/in.toml",
+			out:    "sections/out.toml",
+			format: SampleTOML,
+		},
+		{
+			in:     "sections/in.toml",
+			out:    "sections/out.yaml",
+			format: SampleYAML,
+		},
+	} {
+		in := "testdata/" + f.in
+		out := "testdata/" + f.out
+
+		parser := NewParser()
+		cfg, err := parser.ParsePath(in)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		gen, err := NewGenerator(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		buf := new(bytes.Buffer)
+		if err := gen.Execute(f.format, buf); err != nil {
+			t.Fatalf("unexpected error testing %s: %v", in, err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), mustReadFile(out)) {
+			t.Errorf("generated output does not match %s\n", out)
+			fmt.Println(buf.String())
+			t.Fatal()
+		}
+	}
+}
+
+// Test_Generator_SampleRoundTrip checks that sample configuration files are
+// valid, and set every flag to its default.
+func Test_Generator_SampleRoundTrip(t *testing.T) {
+	toml := `
+	[[flags]]
+	name = "HTTPAddr"
+	cli = "http-addr"
+	type = "string"
+	default = "localhost:4001"
+	short_help = "HTTP server bind address"
+	long_help = """
+Quotes "like these", backslashes \\ and a very long line of help text which will need to be wrapped onto more than one line.
+"""
+
+	[[flags]]
+	name = "JoinAttempts"
+	cli = "join-attempts"
+	type = "int"
+	default = 5
+	short_help = "Number of join attempts"
+
+	[[flags]]
+	name = "FKConstraints"
+	cli = "fk"
+	type = "bool"
+	short_help = "Enable SQLite foreign key constraints"
+
+	[[flags]]
+	name = "JoinInterval"
+	cli = "join-interval"
+	type = "time.Duration"
+	default = "3s"
+	short_help = "Time between join attempts"
+
+	[[flags]]
+	name = "ExtensionPaths"
+	cli = "extensions-path"
+	type = "[]string"
+	default = "a,b"
+	short_help = "Paths to SQLite extensions"
+	`
+	want := map[string]interface{}{
+		"http-addr":       "localhost:4001",
+		"join-attempts":   5,
+		"fk":              false,
+		"join-interval":   "3s",
+		"extensions-path": []interface{}{"a", "b"},
+	}
+
+	for format, typ := range map[Format]string{
+		SampleTOML: "toml",
+		SampleYAML: "yaml",
+	} {
+		src := mustGenerate(t, toml, format)
+		v := viper.New()
+		v.SetConfigType(typ)
+		if err := v.ReadConfig(bytes.NewReader(src)); err != nil {
+			t.Fatalf("%s sample is not valid: %v\n%s", format, err, src)
+		}
+		for key, value := range want {
+			got := v.Get(key)
+			if i, ok := got.(int64); ok {
+				got = int(i)
+			}
+			if !reflect.DeepEqual(got, value) {
+				t.Errorf("%s sample sets %s to %#v, want %#v", format, key, got, value)
+			}
+		}
+	}
+}
+
+func Test_WrapText(t *testing.T) {
+	got := wrapText("one two three four\n\nfive", 9)
+	want := []string{"one two", "three", "four", "", "five"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrapText returned %q, want %q", got, want)
+	}
+}
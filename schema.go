@@ -0,0 +1,15 @@
+package flagforge
+
+import (
+	"bytes"
+	_ "embed"
+)
+
+//go:embed flagforge.schema.json
+var inputSchema []byte
+
+// InputSchema returns a JSON Schema describing flagforge's input file, for use
+// by editors which validate and complete TOML as it is written.
+func InputSchema() []byte {
+	return bytes.Clone(inputSchema)
+}
@@ -0,0 +1,76 @@
+package flagforge
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Test_InputSchema checks that the schema of the input file is in step with
+// the structs it is parsed into, and with the flag types the generators support.
+func Test_InputSchema(t *testing.T) {
+	type object struct {
+		Properties map[string]struct {
+			Enum []string
+		}
+	}
+	var schema struct {
+		Defs map[string]object `json:"$defs"`
+	}
+	if err := json.Unmarshal(InputSchema(), &schema); err != nil {
+		t.Fatalf("input schema is not valid JSON: %v", err)
+	}
+
+	for def, v := range map[string]interface{}{
+		"go":        GoConfig{},
+		"docs":      DocsConfig{},
+		"helm":      HelmConfig{},
+		"env":       EnvConfig{},
+		"systemd":   SystemdConfig{},
+		"templates": TemplatesConfig{},
+		"output":    Output{},
+		"argument":  Argument{},
+		"flag":      Flag{},
+		"section":   Section{},
+	} {
+		var keys []string
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			if key, _, _ := strings.Cut(typ.Field(i).Tag.Get("mapstructure"), ","); key != "" {
+				keys = append(keys, key)
+			}
+		}
+		var props []string
+		for prop := range schema.Defs[def].Properties {
+			props = append(props, prop)
+		}
+		slices.Sort(keys)
+		slices.Sort(props)
+		if !slices.Equal(keys, props) {
+			t.Errorf("schema of %s has properties %v, want %v", def, props, keys)
+		}
+	}
+
+	types := schema.Defs["flag"].Properties["type"].Enum
+	if len(types) == 0 {
+		t.Fatal("schema does not enumerate flag types")
+	}
+	for _, typ := range types {
+		if _, err := flagDefault(Flag{Name: "Test", Type: typ}); err != nil {
+			t.Errorf("schema allows unsupported flag type %s", typ)
+		}
+	}
+
+	formats := schema.Defs["output"].Properties["format"].Enum
+	var names []string
+	for name := range formatNames {
+		names = append(names, name)
+	}
+	slices.Sort(formats)
+	slices.Sort(names)
+	if !slices.Equal(formats, names) {
+		t.Errorf("schema enumerates output formats %v, want %v", formats, names)
+	}
+}
@@ -0,0 +1,130 @@
+package flagforge
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// doSystemdEnv writes a systemd EnvironmentFile setting a variable for each
+// argument and flag, to the flag's default. The ExecStart= line written by
+// doSystemdExecStart leaves those which are empty off the command line, and so
+// the variable of a flag taking its default from another is left empty.
+func (g *Generator) doSystemdEnv(w io.Writer) error {
+	sections, err := groupBySection(g.flags)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	writeVar := func(name, desc, value string) {
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		for _, line := range wrapText(desc, sampleWidth-2) {
+			buf.WriteString(strings.TrimSpace("# "+line) + "\n")
+		}
+		fmt.Fprintf(&buf, "%s=%s\n", name, systemdQuote(value))
+	}
+
+	for _, arg := range g.args {
+		writeVar(g.envName(arg.Name), flagDescription(Flag{
+			ShortHelp: arg.ShortHelp,
+			LongHelp:  arg.LongHelp,
+		}), "")
+	}
+	for _, section := range sections {
+		if section.Name != "" {
+			writeSectionComment(&buf, section.Name)
+		}
+		for _, flag := range section.Flags {
+			if flag.DefaultFrom != "" {
+				def, err := formatDefault(flag)
+				if err != nil {
+					return err
+				}
+				writeVar(g.envName(flag.CLI), flagDescription(flag)+"\n\nIf empty, "+def+".", "")
+				continue
+			}
+			def, err := flagDefault(flag)
+			if err != nil {
+				return err
+			}
+			value := fmt.Sprint(def)
+			if s, ok := def.([]string); ok {
+				delim := flag.Delimiter
+				if delim == "" {
+					delim = ","
+				}
+				value = strings.Join(s, delim)
+			}
+			writeVar(g.envName(flag.CLI), flagDescription(flag), value)
+		}
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write systemd environment file: %w", err)
+	}
+	return nil
+}
+
+// doSystemdExecStart writes a systemd ExecStart= line passing each variable in
+// the file written by doSystemdEnv to the program, as a flag or argument.
+// Variables which are empty are left off the command line, so that the
+// program falls back on its defaults rather than failing to parse an empty
+// value. systemd can't do that itself, so the line runs the program from the
+// shell, writing the shell's "$" as "$$" for systemd to leave alone.
+func (g *Generator) doSystemdExecStart(w io.Writer) error {
+	execStart := g.systemd.ExecStart
+	if execStart == "" {
+		execStart = "/usr/bin/" + g.flagSetName
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("ExecStart=/bin/sh -c 'exec " + execStart)
+	for _, flag := range g.flags {
+		name := g.envName(flag.CLI)
+		fmt.Fprintf(&buf, " \\\n\t$${%s:+\"-%s=$$%s\"}", name, strings.TrimLeft(flag.CLI, "-"), name)
+	}
+	for _, arg := range g.args {
+		name := g.envName(arg.Name)
+		fmt.Fprintf(&buf, " \\\n\t$${%s:+\"$$%s\"}", name, name)
+	}
+	buf.WriteString("'\n")
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write systemd ExecStart line: %w", err)
+	}
+	return nil
+}
+
+// envName returns the name of the environment variable for the flag with the
+// given command-line name, or the argument with the given name: the name in
+// upper snake case, with the configured prefix. With the prefix "RQLITE_",
+// "http-addr" becomes "RQLITE_HTTP_ADDR", and "DataPath" "RQLITE_DATA_PATH".
+func (g *Generator) envName(name string) string {
+	var b strings.Builder
+	runes := []rune(strings.TrimLeft(name, "-"))
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			b.WriteRune('_')
+			continue
+		}
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return g.env.Prefix + b.String()
+}
+
+// systemdQuote quotes s for use as a value in a systemd EnvironmentFile.
+func systemdQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
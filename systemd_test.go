@@ -0,0 +1,262 @@
+package flagforge
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Test_Generator_Systemd checks that the ExecStart= line passes every variable
+// set by the environment file, and nothing else.
+func Test_Generator_Systemd(t *testing.T) {
+	toml := `
+	[env]
+	prefix = "RQLITE_"
+
+	[systemd]
+	exec_start = "/opt/rqlite/rqlited"
+
+	[[arguments]]
+	name = "DataPath"
+	type = "string"
+	short_help = "Path to node data"
+
+	[[flags]]
+	name = "HTTPAddr"
+	cli = "http-addr"
+	type = "string"
+	default = "localhost:4001"
+	short_help = "HTTP server bind address"
+	long_help = "Set to \"0.0.0.0:4001\" to listen on all interfaces."
+
+	[[flags]]
+	name = "JoinAttempts"
+	cli = "join-attempts"
+	type = "int"
+	default = 5
+	short_help = "Number of join attempts"
+
+	[[flags]]
+	name = "ExtensionPaths"
+	cli = "extensions-path"
+	type = "[]string"
+	delimiter = ";"
+	default = "a;b"
+	short_help = "Paths to SQLite extensions"
+
+	[[flags]]
+	name = "JoinAttemptsMax"
+	cli = "join-attempts-max"
+	type = "int"
+	default_from = "join-attempts"
+	short_help = "Most join attempts"
+	`
+
+	env := string(mustGenerate(t, toml, SystemdEnv))
+	for _, s := range []string{
+		"# Most join attempts\n#\n# If empty, same as -join-attempts.\nRQLITE_JOIN_ATTEMPTS_MAX=\"\"\n",
+		"# Set to \"0.0.0.0:4001\" to listen on all interfaces.\nRQLITE_HTTP_ADDR=\"localhost:4001\"\n",
+		"RQLITE_JOIN_ATTEMPTS=\"5\"\n",
+		"RQLITE_EXTENSIONS_PATH=\"a;b\"\n",
+		"RQLITE_DATA_PATH=\"\"\n",
+	} {
+		if !strings.Contains(env, s) {
+			t.Errorf("environment file does not contain %q:\n%s", s, env)
+		}
+	}
+
+	exec := string(mustGenerate(t, toml, SystemdExecStart))
+	if want := `ExecStart=/bin/sh -c 'exec /opt/rqlite/rqlited \
+	$${RQLITE_HTTP_ADDR:+"-http-addr=$$RQLITE_HTTP_ADDR"} \
+	$${RQLITE_JOIN_ATTEMPTS:+"-join-attempts=$$RQLITE_JOIN_ATTEMPTS"} \
+	$${RQLITE_EXTENSIONS_PATH:+"-extensions-path=$$RQLITE_EXTENSIONS_PATH"} \
+	$${RQLITE_JOIN_ATTEMPTS_MAX:+"-join-attempts-max=$$RQLITE_JOIN_ATTEMPTS_MAX"} \
+	$${RQLITE_DATA_PATH:+"$$RQLITE_DATA_PATH"}'
+`; exec != want {
+		t.Fatalf("wrong ExecStart line, got:\n%s\nwant:\n%s", exec, want)
+	}
+
+	set := regexp.MustCompile(`(?m)^([A-Z_]+)=`).FindAllStringSubmatch(env, -1)
+	used := regexp.MustCompile(`\$\$\{([A-Z_]+):`).FindAllStringSubmatch(exec, -1)
+	if len(set) != len(used) {
+		t.Fatalf("environment file sets %d variables, ExecStart uses %d", len(set), len(used))
+	}
+	for i := range set {
+		if !strings.Contains(exec, "$${"+set[i][1]+":") {
+			t.Errorf("ExecStart does not use %s", set[i][1])
+		}
+	}
+}
+
+// Test_Generator_SystemdRun runs a program built from generated code as systemd
+// would, with the generated ExecStart= line and environment file, checking
+// that empty variables leave flags at their defaults.
+func Test_Generator_SystemdRun(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("skipping run of generated code: sh not found")
+	}
+	toml := `
+	[env]
+	prefix = "APP_"
+
+	[[arguments]]
+	name = "DataPath"
+	type = "string"
+	short_help = "Path to node data"
+
+	[[flags]]
+	name = "Greeting"
+	cli = "greeting"
+	type = "string"
+	default = 'say "hi" to \ all'
+	short_help = "Greeting to send"
+
+	[[flags]]
+	name = "HTTPAddr"
+	cli = "http-addr"
+	type = "hostport"
+	short_help = "HTTP server bind address"
+
+	[[flags]]
+	name = "RestoreAt"
+	cli = "restore-at"
+	type = "time.Time"
+	short_help = "Point in time to restore to"
+
+	[[flags]]
+	name = "LogLevel"
+	cli = "log-level"
+	type = "custom"
+	go_type = "slog.Level"
+	import = "log/slog"
+	short_help = "Minimum level of logged messages"
+
+	[[flags]]
+	name = "Tags"
+	cli = "tags"
+	type = "[]string"
+	default = "a b,c"
+	short_help = "Tags to add"
+
+	[[flags]]
+	name = "Port"
+	cli = "port"
+	type = "int"
+	default = 4001
+	short_help = "Port to listen on"
+
+	[[flags]]
+	name = "AdvPort"
+	cli = "adv-port"
+	type = "int"
+	default_from = "port"
+	short_help = "Advertised port"
+	`
+	bin := mustBuildForge(t, toml)
+
+	tomlFile := mustWriteToTempTOMLFile(toml)
+	defer os.Remove(tomlFile)
+	cfg, err := NewParser().ParsePath(tomlFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.SystemdConfig.ExecStart = bin
+	gen, err := NewGenerator(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var envFile, execStart bytes.Buffer
+	if err := gen.Execute(SystemdEnv, &envFile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := gen.Execute(SystemdExecStart, &execStart); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Read the environment file and the ExecStart= line as systemd does:
+	// values are quoted, lines ending in a backslash are continued, and "$$"
+	// is a "$".
+	env := make(map[string]string)
+	for _, line := range strings.Split(envFile.String(), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, _ := strings.Cut(line, "=")
+		v, err := strconv.Unquote(value)
+		if err != nil {
+			t.Fatalf("failed to unquote value of %s: %v", name, err)
+		}
+		env[name] = v
+	}
+	line := strings.ReplaceAll(execStart.String(), " \\\n", " ")
+	line = strings.ReplaceAll(line, "$$", "$")
+	script, ok := strings.CutPrefix(strings.TrimSuffix(line, "'\n"), "ExecStart=/bin/sh -c '")
+	if !ok {
+		t.Fatalf("ExecStart does not run the shell:\n%s", execStart.String())
+	}
+
+	for name, tt := range map[string]struct {
+		env  map[string]string
+		want string
+	}{
+		"Defaults": {
+			env:  map[string]string{"APP_DATA_PATH": "/data"},
+			want: `{DataPath:/data Greeting:say "hi" to \ all HTTPAddr: RestoreAt:0001-01-01 00:00:00 +0000 UTC LogLevel:INFO Tags:[a b c] Port:4001 AdvPort:4001}`,
+		},
+		"Set": {
+			env: map[string]string{
+				"APP_DATA_PATH":  "/my data",
+				"APP_GREETING":   "",
+				"APP_HTTP_ADDR":  "localhost:4001",
+				"APP_RESTORE_AT": "2024-03-01T10:00:00Z",
+				"APP_LOG_LEVEL":  "WARN",
+				"APP_TAGS":       "x y",
+				"APP_PORT":       "80",
+			},
+			want: `{DataPath:/my data Greeting:say "hi" to \ all HTTPAddr:localhost:4001 RestoreAt:2024-03-01 10:00:00 +0000 UTC LogLevel:WARN Tags:[x y] Port:80 AdvPort:80}`,
+		},
+		"Emptied": {
+			env:  map[string]string{"APP_DATA_PATH": "/data", "APP_TAGS": "", "APP_ADV_PORT": "8080"},
+			want: `{DataPath:/data Greeting:say "hi" to \ all HTTPAddr: RestoreAt:0001-01-01 00:00:00 +0000 UTC LogLevel:INFO Tags:[a b c] Port:4001 AdvPort:8080}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cmd := exec.Command(sh, "-c", script)
+			cmd.Env = os.Environ()
+			for name, value := range env {
+				if v, ok := tt.env[name]; ok {
+					value = v
+				}
+				cmd.Env = append(cmd.Env, name+"="+value)
+			}
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("failed to run generated code: %v\n%s", err, out)
+			}
+			if got := strings.TrimSpace(string(out)); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Generator_EnvName(t *testing.T) {
+	g := &Generator{env: EnvConfig{Prefix: "APP_"}}
+	for name, want := range map[string]string{
+		"http-addr":          "APP_HTTP_ADDR",
+		"raft-snap-wal-size": "APP_RAFT_SNAP_WAL_SIZE",
+		"-node-id":           "APP_NODE_ID",
+		"DataPath":           "APP_DATA_PATH",
+		"HTTPAddr":           "APP_HTTP_ADDR",
+		"X509Cert":           "APP_X509_CERT",
+	} {
+		if got := g.envName(name); got != want {
+			t.Errorf("envName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
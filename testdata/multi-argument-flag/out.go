@@ -5,6 +5,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
@@ -20,10 +21,43 @@ type Config struct {
 	HTTPAddr string
 }
 
+// ForgeOption configures ForgeWithOptions.
+type ForgeOption func(*forgeOptions)
+
+type forgeOptions struct {
+	output io.Writer
+}
+
+// WithOutput sets the destination for usage and error messages. If not set,
+// they are written to os.Stderr.
+func WithOutput(w io.Writer) ForgeOption {
+	return func(o *forgeOptions) {
+		o.output = w
+	}
+}
+
 // Forge sets up and parses command-line flags.
 func Forge(arguments []string) (*flag.FlagSet, *Config, error) {
+	return forge(arguments, os.Stderr, flag.ExitOnError)
+}
+
+// ForgeWithOptions sets up and parses command-line flags. Unlike Forge it
+// never exits the process or panics, regardless of the configured error
+// handling: any parse error, including flag.ErrHelp, is returned.
+func ForgeWithOptions(arguments []string, opts ...ForgeOption) (*flag.FlagSet, *Config, error) {
+	o := &forgeOptions{
+		output: os.Stderr,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return forge(arguments, o.output, flag.ContinueOnError)
+}
+
+func forge(arguments []string, output io.Writer, errorHandling flag.ErrorHandling) (*flag.FlagSet, *Config, error) {
 	config := &Config{}
-	fs := flag.NewFlagSet("name", flag.ExitOnError)
+	fs := flag.NewFlagSet("name", errorHandling)
+	fs.SetOutput(output)
 	if len(arguments) <= 0 {
 		return nil, nil, fmtError("missing required argument: DataDir")
 	}
@@ -55,6 +89,6 @@ func fmtError(msg string) error {
 	return errors.New(msg)
 }
 
-func usage(msg string) {
-	fmt.Fprintf(os.Stderr, "%s", msg)
+func usage(w io.Writer, msg string) {
+	fmt.Fprintf(w, "%s", msg)
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
@@ -126,10 +127,43 @@ type Config struct {
 	TraceProfile string
 }
 
+// ForgeOption configures ForgeWithOptions.
+type ForgeOption func(*forgeOptions)
+
+type forgeOptions struct {
+	output io.Writer
+}
+
+// WithOutput sets the destination for usage and error messages. If not set,
+// they are written to os.Stderr.
+func WithOutput(w io.Writer) ForgeOption {
+	return func(o *forgeOptions) {
+		o.output = w
+	}
+}
+
 // Forge sets up and parses command-line flags.
 func Forge(arguments []string) (*flag.FlagSet, *Config, error) {
+	return forge(arguments, os.Stderr, flag.ExitOnError)
+}
+
+// ForgeWithOptions sets up and parses command-line flags. Unlike Forge it
+// never exits the process or panics, regardless of the configured error
+// handling: any parse error, including flag.ErrHelp, is returned.
+func ForgeWithOptions(arguments []string, opts ...ForgeOption) (*flag.FlagSet, *Config, error) {
+	o := &forgeOptions{
+		output: os.Stderr,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return forge(arguments, o.output, flag.ContinueOnError)
+}
+
+func forge(arguments []string, output io.Writer, errorHandling flag.ErrorHandling) (*flag.FlagSet, *Config, error) {
 	config := &Config{}
-	fs := flag.NewFlagSet("rqlited", flag.ExitOnError)
+	fs := flag.NewFlagSet("rqlited", errorHandling)
+	fs.SetOutput(output)
 	if len(arguments) <= 0 {
 		return nil, nil, fmtError("missing required argument: DataPath")
 	}
@@ -190,7 +224,7 @@ func Forge(arguments []string) (*flag.FlagSet, *Config, error) {
 	fs.StringVar(&config.MemProfile, "mem-profile", "", "Write memory profie information to a file at this path")
 	fs.StringVar(&config.TraceProfile, "trace-profile", "", "Path to file for trace profiling information")
 	fs.Usage = func() {
-		usage("\nrqlite is a lightweight, distributed relational database, which uses SQLite as its\nstorage engine. It provides an easy-to-use, fault-tolerant store for relational data.\n\nVisit https://www.rqlite.io to learn more.\n\nUsage: rqlited [flags] <data directory>\n")
+		usage(fs.Output(), "\nrqlite is a lightweight, distributed relational database, which uses SQLite as its\nstorage engine. It provides an easy-to-use, fault-tolerant store for relational data.\n\nVisit https://www.rqlite.io to learn more.\n\nUsage: rqlited [flags] <data directory>\n")
 		fs.PrintDefaults()
 	}
 	if err := fs.Parse(arguments); err != nil {
@@ -220,6 +254,6 @@ func fmtError(msg string) error {
 	return errors.New(msg)
 }
 
-func usage(msg string) {
-	fmt.Fprintf(os.Stderr, "%s", msg)
+func usage(w io.Writer, msg string) {
+	fmt.Fprintf(w, "%s", msg)
 }